@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// unknownSegment names the operator or platform of events without one
+const unknownSegment = "unknown"
+
+// SegmentStat is the activity of an operator or platform. FlaggedEvents
+// counts the suspicious events raised for its players or for the segment
+// itself and FlaggedPlayers its players with at least one such event.
+type SegmentStat struct {
+	ID             string  `json:"id"`
+	TotalBets      int     `json:"total_bets"`
+	TotalWins      int     `json:"total_wins"`
+	TotalBetAmount int64   `json:"total_bet_amount"`
+	TotalWinAmount int64   `json:"total_win_amount"`
+	NetResult      int64   `json:"net_result"`
+	RTP            float64 `json:"rtp_percentage"`
+	Players        int     `json:"players"`
+	FlaggedEvents  int     `json:"flagged_events"`
+	FlaggedPlayers int     `json:"flagged_players"`
+}
+
+// segmentTotals returns the totals of a segment, adding it on first use
+func segmentTotals(segments map[string]*activityTotals, id string) *activityTotals {
+	totals := segments[id]
+	if totals == nil {
+		totals = &activityTotals{players: make(map[string]bool)}
+		segments[id] = totals
+	}
+	return totals
+}
+
+// segmentStats turns the totals per segment into statistics. Events are
+// attributed to the segment segmentOf returns for them. Events without one,
+// such as a player's RTP, count towards every segment the player was active
+// in.
+func segmentStats(segments map[string]*activityTotals, events []SuspiciousEvent, segmentOf func(SuspiciousEvent) string) map[string]SegmentStat {
+	playerSegments := make(map[string][]string)
+	for id, totals := range segments {
+		for playerID := range totals.players {
+			playerSegments[playerID] = append(playerSegments[playerID], id)
+		}
+	}
+
+	flaggedEvents := make(map[string]int)
+	flaggedPlayers := make(map[string]map[string]bool)
+	for _, event := range events {
+		var ids []string
+		switch id := segmentOf(event); {
+		case id != "":
+			ids = []string{id}
+		case event.PlayerID != "":
+			ids = playerSegments[event.PlayerID]
+		}
+		for _, id := range ids {
+			flaggedEvents[id]++
+			if event.PlayerID == "" {
+				continue
+			}
+			if flaggedPlayers[id] == nil {
+				flaggedPlayers[id] = make(map[string]bool)
+			}
+			flaggedPlayers[id][event.PlayerID] = true
+		}
+	}
+
+	stats := make(map[string]SegmentStat, len(segments))
+	for id, totals := range segments {
+		stat := SegmentStat{
+			ID:             id,
+			TotalBets:      totals.bets,
+			TotalWins:      totals.wins,
+			TotalBetAmount: totals.betAmount,
+			TotalWinAmount: totals.winAmount,
+			NetResult:      totals.winAmount - totals.betAmount,
+			RTP:            totals.rtp(),
+			Players:        len(totals.players),
+			FlaggedEvents:  flaggedEvents[id],
+			FlaggedPlayers: len(flaggedPlayers[id]),
+		}
+		if stat.ID == "" {
+			stat.ID = unknownSegment
+		}
+		stats[stat.ID] = stat
+	}
+	return stats
+}
+
+// sortedSegments returns the segments with the largest bet volume first
+func sortedSegments(stats map[string]SegmentStat) []SegmentStat {
+	segments := make([]SegmentStat, 0, len(stats))
+	for _, stat := range stats {
+		segments = append(segments, stat)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].TotalBetAmount != segments[j].TotalBetAmount {
+			return segments[i].TotalBetAmount > segments[j].TotalBetAmount
+		}
+		return segments[i].ID < segments[j].ID
+	})
+	return segments
+}
+
+func printSegments(w io.Writer, title, label string, stats map[string]SegmentStat, currency currencyFormat) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, stat := range sortedSegments(stats) {
+		flag := ""
+		if stat.FlaggedEvents > 0 {
+			flag = " ⚠️"
+		}
+		fmt.Fprintf(w, "%s: %s\n", label, stat.ID)
+		fmt.Fprintf(w, "├─ Bets: %d, Wins: %d, Players: %d\n", stat.TotalBets, stat.TotalWins, stat.Players)
+		fmt.Fprintf(w, "├─ Volume: Bet %s, Win %s, Net %s\n",
+			formatMoney(stat.TotalBetAmount, currency), formatMoney(stat.TotalWinAmount, currency), formatMoney(stat.NetResult, currency))
+		fmt.Fprintf(w, "├─ RTP: %.2f%%\n", stat.RTP)
+		fmt.Fprintf(w, "└─ Flagged: %d events, %d players%s\n", stat.FlaggedEvents, stat.FlaggedPlayers, flag)
+	}
+}
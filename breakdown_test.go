@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestSegmentStatsAttributesEventsToTheirSegment(t *testing.T) {
+	segments := make(map[string]*activityTotals)
+	for _, data := range []GameData{
+		{PlayerID: "p1", OperatorID: "op1"},
+		{PlayerID: "p1", OperatorID: "op2"},
+		{PlayerID: "p2", OperatorID: "op2"},
+	} {
+		segmentTotals(segments, data.OperatorID).players[data.PlayerID] = true
+	}
+
+	events := []SuspiciousEvent{
+		// A round of p1 with op2
+		{Type: EventOrphanWin, PlayerID: "p1", OperatorID: "op2", RoundID: "r1"},
+		// p1 as a whole, without an operator
+		{Type: EventHighRTP, PlayerID: "p1"},
+		// An operator-scoped rule
+		{Type: EventHighRTP, OperatorID: "op1"},
+	}
+
+	stats := segmentStats(segments, events, func(event SuspiciousEvent) string {
+		return event.OperatorID
+	})
+
+	for id, want := range map[string]struct{ events, players int }{
+		"op1": {2, 1},
+		"op2": {2, 1},
+	} {
+		stat := stats[id]
+		if stat.FlaggedEvents != want.events || stat.FlaggedPlayers != want.players {
+			t.Errorf("%s: got %d flagged events and %d players, want %d and %d",
+				id, stat.FlaggedEvents, stat.FlaggedPlayers, want.events, want.players)
+		}
+	}
+}
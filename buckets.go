@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bucketSizes are the selectable lengths of the activity timeline buckets
+var bucketSizes = []struct {
+	name string
+	size time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// bucketing selects how activity is grouped over time: Size is the length
+// of the timeline buckets, Heatmap adds the hour-of-week heatmap
+type bucketing struct {
+	Size    bucketSize
+	Heatmap bool
+}
+
+// bucketSize is the length of a timeline bucket, set as 1m, 5m, 15m, 1h or
+// 1d
+type bucketSize time.Duration
+
+func (s *bucketSize) String() string {
+	if s == nil {
+		return ""
+	}
+	for _, b := range bucketSizes {
+		if b.size == time.Duration(*s) {
+			return b.name
+		}
+	}
+	return time.Duration(*s).String()
+}
+
+func (s *bucketSize) Set(value string) error {
+	names := make([]string, len(bucketSizes))
+	for i, b := range bucketSizes {
+		if b.name == value {
+			*s = bucketSize(b.size)
+			return nil
+		}
+		names[i] = b.name
+	}
+	return fmt.Errorf("unknown bucket size %q, expected one of %s", value, strings.Join(names, ", "))
+}
+
+// start returns the start of the bucket holding t. Buckets are aligned to
+// midnight in the zone of t; day buckets are calendar days, so they follow
+// daylight saving changes.
+func (s bucketSize) start(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if s.days() {
+		return midnight
+	}
+	return midnight.Add(t.Sub(midnight).Truncate(time.Duration(s)))
+}
+
+// end returns the end of the bucket starting at start
+func (s bucketSize) end(start time.Time) time.Time {
+	if s.days() {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(time.Duration(s))
+}
+
+func (s bucketSize) days() bool {
+	return time.Duration(s) >= 24*time.Hour
+}
+
+// label formats the start of a bucket for the text report
+func (s bucketSize) label(start time.Time) string {
+	if s.days() {
+		return start.Format(dateLayout)
+	}
+	return start.Format("2006-01-02 15:04")
+}
+
+// Activity is the betting activity of a period. ActivePlayers counts the
+// players with at least one event in it.
+type Activity struct {
+	TotalBets      int     `json:"total_bets"`
+	TotalWins      int     `json:"total_wins"`
+	TotalBetAmount int64   `json:"total_bet_amount"`
+	TotalWinAmount int64   `json:"total_win_amount"`
+	RTP            float64 `json:"rtp_percentage"`
+	ActivePlayers  int     `json:"active_players"`
+}
+
+// TimeStat is the activity of one timeline bucket, from Start up to End
+type TimeStat struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Activity
+
+	// label is the start as shown in the text report
+	label string
+}
+
+// ActivityHeatmap spreads the activity over the hours of the week in the
+// reporting zone. Days are indexed like time.Weekday, Sunday first.
+type ActivityHeatmap struct {
+	Days [7][24]Activity `json:"days"`
+}
+
+// activityCounter accumulates the activity of a period while data is
+// streamed
+type activityCounter struct {
+	Activity
+	players map[string]bool
+}
+
+func newActivityCounter() *activityCounter {
+	return &activityCounter{players: make(map[string]bool)}
+}
+
+func (c *activityCounter) add(data GameData) {
+	c.players[data.PlayerID] = true
+
+	if data.Message == "SendBet" && data.Bet > 0 {
+		c.TotalBets++
+		c.TotalBetAmount += data.Bet
+	} else if data.Message == "SendWin" && data.Win > 0 {
+		c.TotalWins++
+		c.TotalWinAmount += data.Win
+	}
+}
+
+func (c *activityCounter) activity() Activity {
+	activity := c.Activity
+	activity.ActivePlayers = len(c.players)
+	if activity.TotalBetAmount > 0 {
+		activity.RTP = float64(activity.TotalWinAmount) / float64(activity.TotalBetAmount) * 100
+	}
+	return activity
+}
+
+// activityTimeline groups the activity into buckets of absolute time and,
+// when enabled, into the hours of the week
+type activityTimeline struct {
+	size    bucketSize
+	zones   *timeZones
+	buckets map[int64]*timelineBucket
+
+	// heatmap is nil unless the heatmap is enabled
+	heatmap *[7][24]*activityCounter
+}
+
+type timelineBucket struct {
+	start   time.Time
+	counter *activityCounter
+}
+
+func newActivityTimeline(buckets bucketing, zones *timeZones) *activityTimeline {
+	timeline := &activityTimeline{size: buckets.Size, zones: zones, buckets: make(map[int64]*timelineBucket)}
+	if buckets.Heatmap {
+		timeline.heatmap = &[7][24]*activityCounter{}
+	}
+	return timeline
+}
+
+func (t *activityTimeline) add(data GameData) {
+	at := t.zones.at(data.Time, data.OperatorID)
+
+	start := t.size.start(at)
+	bucket := t.buckets[start.Unix()]
+	if bucket == nil {
+		bucket = &timelineBucket{start: start, counter: newActivityCounter()}
+		t.buckets[start.Unix()] = bucket
+	}
+	bucket.counter.add(data)
+
+	if t.heatmap != nil {
+		cell := &t.heatmap[at.Weekday()][at.Hour()]
+		if *cell == nil {
+			*cell = newActivityCounter()
+		}
+		(*cell).add(data)
+	}
+}
+
+// stats returns the buckets in chronological order
+func (t *activityTimeline) stats() []TimeStat {
+	buckets := make([]*timelineBucket, 0, len(t.buckets))
+	for _, bucket := range t.buckets {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].start.Before(buckets[j].start)
+	})
+
+	stats := make([]TimeStat, len(buckets))
+	for i, bucket := range buckets {
+		stats[i] = TimeStat{
+			Start:    bucket.start.Format(timeLayout),
+			End:      t.size.end(bucket.start).Format(timeLayout),
+			Activity: bucket.counter.activity(),
+			label:    t.size.label(bucket.start),
+		}
+	}
+	return stats
+}
+
+// heatmapStats returns the heatmap, or nil when it is not enabled
+func (t *activityTimeline) heatmapStats() *ActivityHeatmap {
+	if t.heatmap == nil {
+		return nil
+	}
+
+	heatmap := &ActivityHeatmap{}
+	for day, hours := range t.heatmap {
+		for hour, counter := range hours {
+			if counter != nil {
+				heatmap.Days[day][hour] = counter.activity()
+			}
+		}
+	}
+	return heatmap
+}
+
+// heatmapShades are drawn for empty hours and for hours up to a quarter,
+// half, three quarters and all of the bets of the busiest hour
+var heatmapShades = []string{"·", "░", "▒", "▓", "█"}
+
+// heatmapShade returns the shade index of an hour with bets out of maxBets
+func heatmapShade(bets, maxBets int) int {
+	if bets == 0 || maxBets == 0 {
+		return 0
+	}
+	return min(4, 1+(bets*4-1)/maxBets)
+}
+
+// weekdaysFromMonday orders the heatmap rows for display
+var weekdaysFromMonday = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+func printTimeline(w io.Writer, report Report, currency currencyFormat) {
+	fmt.Fprintf(w, "\n⏰ ACTIVITY TIMELINE (%s buckets):\n", report.TimeBucket)
+	for _, stat := range report.TimeStats {
+		if stat.TotalBets > 0 {
+			fmt.Fprintf(w, "%s - Bets: %4d, Wins: %4d, Players: %3d, RTP: %7.2f%%, Volume: %s\n",
+				stat.label, stat.TotalBets, stat.TotalWins, stat.ActivePlayers, stat.RTP,
+				formatMoney(stat.TotalBetAmount, currency))
+		}
+	}
+}
+
+func printHeatmap(w io.Writer, heatmap *ActivityHeatmap, currency currencyFormat, limit int) {
+	type slot struct {
+		day  time.Weekday
+		hour int
+		Activity
+	}
+	var slots []slot
+	maxBets := 0
+	for day, hours := range heatmap.Days {
+		for hour, activity := range hours {
+			if activity.TotalBets > 0 {
+				slots = append(slots, slot{time.Weekday(day), hour, activity})
+				maxBets = max(maxBets, activity.TotalBets)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "\n🗓️  WEEKLY HEATMAP (bets by weekday and hour):")
+	fmt.Fprint(w, "    ")
+	for hour := 0; hour < 24; hour++ {
+		fmt.Fprintf(w, " %02d", hour)
+	}
+	fmt.Fprintln(w)
+	for _, day := range weekdaysFromMonday {
+		fmt.Fprintf(w, "%s ", day.String()[:3])
+		for _, activity := range heatmap.Days[day] {
+			shade := heatmapShades[heatmapShade(activity.TotalBets, maxBets)]
+			fmt.Fprintf(w, " %s%s", shade, shade)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "├─ Scale: ░ ▒ ▓ █ up to a quarter, half, three quarters and all of %d bets, · none\n", maxBets)
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].TotalBets > slots[j].TotalBets
+	})
+	slots = slots[:min(limit, len(slots))]
+	for i, s := range slots {
+		branch := "├─"
+		if i == len(slots)-1 {
+			branch = "└─"
+		}
+		fmt.Fprintf(w, "%s Busiest: %s %02d:00 - Bets: %d, Players: %d, RTP: %.2f%%, Volume: %s\n",
+			branch, s.day.String()[:3], s.hour, s.TotalBets, s.ActivePlayers, s.RTP, formatMoney(s.TotalBetAmount, currency))
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// config holds the options of a single analysis run
+type config struct {
+	Inputs    []string
+	Recursive bool
+	Output    string
+	Format    string
+	CSVDir    string
+
+	// RatesFile converts all amounts into ReportingCurrency, which
+	// defaults to the base currency of the rates file
+	RatesFile         string
+	ReportingCurrency string
+	Daily             bool
+	Buckets           bucketing
+	Locale            locale
+
+	// Filter restricts the analysis to the matching events, nil for all
+	Filter *eventFilter
+
+	// Thresholds set the defaults of the built-in rules, which RulesFile
+	// can change
+	Thresholds thresholds
+	RulesFile  string
+	Loki       lokiConfig
+
+	// ExportLimit is the line cap of a Loki export; files holding exactly
+	// this many entries are reported as likely truncated
+	ExportLimit int
+	MaxGap      time.Duration
+}
+
+// locale selects how amounts and times are read and reported
+type locale struct {
+	// MinorUnits overrides the minor unit exponent of currencies and of
+	// the amounts of single operators
+	MinorUnits minorUnitOverrides
+	Zones      *timeZones
+}
+
+// lokiConfig selects direct ingestion from the Loki HTTP API
+type lokiConfig struct {
+	URL       string
+	Query     string
+	OrgID     string
+	Limit     int
+	MinWindow time.Duration
+	From      time.Time
+	To        time.Time
+}
+
+// thresholds configures the high RTP and spin rate rules
+type thresholds struct {
+	HighRTP           float64
+	HighRTPMinBets    int
+	MaxSpinsPerMinute int
+}
+
+func defaultThresholds() thresholds {
+	return thresholds{
+		HighRTP:           150,
+		HighRTPMinBets:    100,
+		MaxSpinsPerMinute: 30,
+	}
+}
+
+func parseConfig(args []string, stderr io.Writer) (config, error) {
+	cfg := config{
+		Thresholds: defaultThresholds(),
+		Buckets:    bucketing{Size: bucketSize(time.Hour)},
+		Locale: locale{
+			MinorUnits: minorUnitOverrides{},
+			Zones:      &timeZones{Default: time.UTC, Operators: map[string]*time.Location{}},
+		},
+	}
+
+	fs := flag.NewFlagSet("fraud-detector", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: fraud-detector [flags] [file|dir|glob ...]\n")
+		fmt.Fprintf(fs.Output(), "       fraud-detector -loki-url URL -query LOGQL [-from TIME] [-to TIME]\n\n")
+		fmt.Fprintf(fs.Output(), "Analyzes Loki JSON exports. Without arguments all *.json files\n")
+		fmt.Fprintf(fs.Output(), "in the current directory are analyzed.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	fs.BoolVar(&cfg.Recursive, "r", false, "descend into sub-directories of directory inputs")
+	fs.StringVar(&cfg.Output, "o", "", "write the report to `file` instead of stdout")
+	fs.StringVar(&cfg.Format, "format", formatText, "report `format`: text, json or html")
+	fs.StringVar(&cfg.CSVDir, "csv", "", "also export player, game, operator, platform, timeline, heatmap, suspicious and cluster tables as CSV files into `dir`")
+	fs.StringVar(&cfg.RatesFile, "rates", "", "JSON `file` with exchange rates used to combine currencies")
+	fs.StringVar(&cfg.ReportingCurrency, "currency", "", "reporting `currency` for -rates (default the base of the rates file)")
+	fs.Var(cfg.Locale.MinorUnits, "minor-units", "minor unit exponent `overrides` as CUR=exp or OPERATOR:CUR=exp, comma-separated")
+	fs.Var(cfg.Locale.Zones, "tz", "reporting time `zones` as ZONE or OPERATOR=ZONE, comma-separated (IANA name, Local or +HH:MM, default UTC)")
+	fs.BoolVar(&cfg.Daily, "daily", true, "print a report per calendar day before the overall summary")
+	fs.Var(&cfg.Buckets.Size, "bucket", "`length` of the activity timeline buckets: 1m, 5m, 15m, 1h or 1d")
+	fs.BoolVar(&cfg.Buckets.Heatmap, "heatmap", false, "add an hour-of-day by day-of-week activity heatmap")
+	fs.Float64Var(&cfg.Thresholds.HighRTP, "high-rtp", cfg.Thresholds.HighRTP, "flag players with RTP above this `percentage`")
+	fs.IntVar(&cfg.Thresholds.HighRTPMinBets, "high-rtp-min-bets", cfg.Thresholds.HighRTPMinBets, "minimum `bets` before the RTP check applies")
+	fs.IntVar(&cfg.Thresholds.MaxSpinsPerMinute, "max-spins", cfg.Thresholds.MaxSpinsPerMinute, "flag players exceeding this many `spins` per minute")
+	fs.StringVar(&cfg.RulesFile, "rules", "", "JSON `file` enabling, tuning or adding detection rules")
+
+	fs.IntVar(&cfg.ExportLimit, "export-limit", 1000, "flag files with exactly this many `entries` as truncated (0 disables)")
+	fs.DurationVar(&cfg.MaxGap, "max-gap", 15*time.Minute, "report gaps longer than `duration` between neighbouring files")
+
+	var filter, operators string
+	fs.StringVar(&filter, "filter", "", "analyze only the events matching `expression`, e.g. \"game=g1 time=20:00-23:00 amount>=100\"")
+	fs.StringVar(&operators, "operator", "", "analyze only the events of these `operators`, comma-separated (same as -filter operator=...)")
+
+	var from, to string
+	cfg.Loki.Limit = 1000
+	cfg.Loki.MinWindow = time.Second
+	fs.StringVar(&cfg.Loki.URL, "loki-url", "", "query the Loki server at `url` instead of reading exports")
+	fs.StringVar(&cfg.Loki.Query, "query", "", "LogQL `selector` used with -loki-url")
+	fs.StringVar(&cfg.Loki.OrgID, "loki-org", "", "tenant `id` sent as X-Scope-OrgID")
+	fs.IntVar(&cfg.Loki.Limit, "loki-limit", cfg.Loki.Limit, "maximum `lines` Loki returns per request")
+	fs.DurationVar(&cfg.Loki.MinWindow, "loki-min-window", cfg.Loki.MinWindow, "smallest `window` a query range is split into")
+	fs.StringVar(&from, "from", "", "start of the Loki query range (RFC 3339, \"2006-01-02 15:04\" or Unix `time`, default 24h before -to)")
+	fs.StringVar(&to, "to", "", "end of the Loki query range (default now)")
+
+	if err := fs.Parse(args); err != nil {
+		return config{}, err
+	}
+
+	if cfg.ReportingCurrency != "" && cfg.RatesFile == "" {
+		return config{}, fmt.Errorf("-currency requires -rates")
+	}
+
+	switch cfg.Format {
+	case formatText, formatJSON, formatHTML:
+	default:
+		return config{}, fmt.Errorf("unknown -format %q", cfg.Format)
+	}
+
+	if operators = strings.ReplaceAll(operators, " ", ""); operators != "" {
+		filter = "operator=" + operators + " " + filter
+	}
+	if strings.TrimSpace(filter) != "" {
+		f, err := parseFilter(filter, cfg.Locale)
+		if err != nil {
+			return config{}, err
+		}
+		cfg.Filter = f
+	}
+
+	cfg.Inputs = fs.Args()
+
+	if cfg.Loki.URL == "" {
+		if len(cfg.Inputs) == 0 {
+			cfg.Inputs = []string{"*.json"}
+		}
+		return cfg, nil
+	}
+
+	if cfg.Loki.Query == "" {
+		return config{}, fmt.Errorf("-query is required with -loki-url")
+	}
+	if cfg.Loki.Limit <= 0 {
+		return config{}, fmt.Errorf("-loki-limit must be positive")
+	}
+
+	cfg.Loki.To = time.Now()
+	if to != "" {
+		t, err := parseTime(to, cfg.Locale.Zones.Default)
+		if err != nil {
+			return config{}, fmt.Errorf("parsing -to: %w", err)
+		}
+		cfg.Loki.To = t
+	}
+
+	cfg.Loki.From = cfg.Loki.To.Add(-24 * time.Hour)
+	if from != "" {
+		t, err := parseTime(from, cfg.Locale.Zones.Default)
+		if err != nil {
+			return config{}, fmt.Errorf("parsing -from: %w", err)
+		}
+		cfg.Loki.From = t
+	}
+
+	if !cfg.Loki.From.Before(cfg.Loki.To) {
+		return config{}, fmt.Errorf("-from must be before -to")
+	}
+
+	return cfg, nil
+}
+
+// resolveInputs expands files, directories and glob patterns into a sorted
+// list of unique JSON files
+func resolveInputs(inputs []string, recursive bool) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, input := range inputs {
+		matches := []string{input}
+		if hasGlobMeta(input) {
+			var err error
+			matches, err = filepath.Glob(input)
+			if err != nil {
+				return nil, fmt.Errorf("globbing %s: %w", input, err)
+			}
+		}
+
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading input: %w", err)
+			}
+
+			if !info.IsDir() {
+				add(path)
+				continue
+			}
+
+			dirFiles, err := findJSONFiles(path, recursive)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range dirFiles {
+				add(file)
+			}
+		}
+	}
+
+	// Sort files by name for consistent processing order
+	sort.Strings(files)
+	return files, nil
+}
+
+func findJSONFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", dir, err)
+	}
+
+	return files, nil
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
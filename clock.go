@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// EventTimestampMismatch flags a player whose event timestamps disagree
+// with the time Loki recorded the log lines
+const EventTimestampMismatch EventType = "Timestamp Mismatch"
+
+// resolveTime sets the event time from ts, or from the Loki timestamp of
+// the log entry for entries without ts. The Loki time is kept in LogTime
+// but never replaces ts: it depends on ingestion, so it only orders events
+// logged with the same time and step, see loggedBefore.
+func (data *GameData) resolveTime(logTimestamp string) {
+	var logTime time.Time
+	if logTimestamp != "" {
+		if t, err := time.Parse(time.RFC3339Nano, logTimestamp); err == nil {
+			logTime = t
+		}
+	}
+
+	if data.Timestamp <= 0 {
+		if logTime.IsZero() {
+			return
+		}
+		data.Time = logTime
+		data.Timestamp = unixSeconds(logTime)
+		return
+	}
+
+	data.Time = unixTime(data.Timestamp)
+	data.LogTime = logTime
+}
+
+// loggedBefore breaks the tie between two events with the same time and
+// step by the time Loki recorded them, so a bet and its win logged in the
+// same whole second keep their order. ok is false when either has no Loki
+// time or both were recorded at once.
+func loggedBefore(a, b time.Time) (before, ok bool) {
+	if a.IsZero() || b.IsZero() || a.Equal(b) {
+		return false, false
+	}
+	return a.Before(b), true
+}
+
+// ClockSkew returns how far the event time is ahead of the Loki time, or
+// false when the entry has no Loki time
+func (data GameData) ClockSkew() (time.Duration, bool) {
+	if data.LogTime.IsZero() || data.Time.IsZero() {
+		return 0, false
+	}
+	return data.Time.Sub(data.LogTime), true
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// unixTime converts Unix seconds with a fraction into a time
+func unixTime(ts float64) time.Time {
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9)))
+}
+
+// TimestampSkew summarizes the difference between the event time and the
+// Loki time of a player's log entries
+type TimestampSkew struct {
+	Entries int `json:"entries"`
+
+	// MaxSec is the largest absolute difference and MaxSkewSec the signed
+	// difference of that entry; positive values are ahead of Loki
+	MaxSec     float64 `json:"max_sec"`
+	MaxSkewSec float64 `json:"max_skew_sec"`
+	MeanSec    float64 `json:"mean_sec"`
+	MaxAt      string  `json:"max_at,omitempty"`
+
+	// sorted holds the absolute differences in ascending order
+	sorted []float64
+}
+
+// exceeding counts the entries differing by more than tolerance seconds
+func (s *TimestampSkew) exceeding(tolerance float64) int {
+	return len(s.sorted) - sort.SearchFloat64s(s.sorted, math.Nextafter(tolerance, math.Inf(1)))
+}
+
+// skewTracker collects the timestamp differences of every player while
+// data is streamed
+type skewTracker struct {
+	players map[string]*skewSums
+}
+
+type skewSums struct {
+	skews  []float64
+	maxAbs float64
+	max    float64
+	maxAt  time.Time
+	maxOp  string
+}
+
+func newSkewTracker() *skewTracker {
+	return &skewTracker{players: make(map[string]*skewSums)}
+}
+
+func (t *skewTracker) add(data GameData) {
+	skew, ok := data.ClockSkew()
+	if !ok || data.PlayerID == "" {
+		return
+	}
+
+	sums := t.players[data.PlayerID]
+	if sums == nil {
+		sums = &skewSums{}
+		t.players[data.PlayerID] = sums
+	}
+	sec := skew.Seconds()
+	sums.skews = append(sums.skews, math.Abs(sec))
+	if math.Abs(sec) > sums.maxAbs {
+		sums.maxAbs = math.Abs(sec)
+		sums.max = sec
+		sums.maxAt = data.Time
+		sums.maxOp = data.OperatorID
+	}
+}
+
+// summarize returns the skew statistics per player
+func (t *skewTracker) summarize(zones *timeZones) map[string]*TimestampSkew {
+	summaries := make(map[string]*TimestampSkew, len(t.players))
+	for playerID, sums := range t.players {
+		sort.Float64s(sums.skews)
+
+		var total float64
+		for _, skew := range sums.skews {
+			total += skew
+		}
+		summary := &TimestampSkew{
+			Entries:    len(sums.skews),
+			MaxSec:     sums.maxAbs,
+			MaxSkewSec: sums.max,
+			MeanSec:    total / float64(len(sums.skews)),
+			sorted:     sums.skews,
+		}
+		if !sums.maxAt.IsZero() {
+			summary.MaxAt = zones.timestamp(sums.maxAt, sums.maxOp)
+		}
+		summaries[playerID] = summary
+	}
+	return summaries
+}
+
+// timestampMismatchDetector flags players with at least min_entries log
+// entries whose ts differs from the Loki timestamp by more than
+// max_skew_sec seconds
+type timestampMismatchDetector struct{}
+
+func (timestampMismatchDetector) Rule() Rule {
+	return Rule{
+		Name:     "timestamp_mismatch",
+		Enabled:  true,
+		Severity: SeverityLow,
+		Scope:    ScopePlayer,
+		Params:   map[string]float64{"max_skew_sec": 5, "min_entries": 1},
+	}
+}
+
+func (timestampMismatchDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (timestampMismatchDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	tolerance := rule.param("max_skew_sec")
+	var events []SuspiciousEvent
+
+	for _, playerID := range sortedKeys(data.report.PlayerStats) {
+		skew := data.report.PlayerStats[playerID].TimestampSkew
+		if skew == nil {
+			continue
+		}
+		count := skew.exceeding(tolerance)
+		if count == 0 || count < int(rule.param("min_entries")) {
+			continue
+		}
+
+		events = append(events, SuspiciousEvent{
+			Type:        EventTimestampMismatch,
+			Description: "Event timestamps disagree with the Loki timestamps (clock skew or replayed events)",
+			PlayerID:    playerID,
+			Timestamp:   skew.MaxAt,
+			Details: fmt.Sprintf("%d of %d entries differ by more than %gs, largest difference %+.3fs, mean %.3fs",
+				count, skew.Entries, tolerance, skew.MaxSkewSec, skew.MeanSec),
+		})
+	}
+
+	return events
+}
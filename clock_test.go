@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestResolveTimeKeepsWholeSecondTs(t *testing.T) {
+	data := GameData{Timestamp: 1766779200}
+	data.resolveTime("2025-12-26T20:00:00.700Z")
+
+	if want := time.Unix(1766779200, 0); !data.Time.Equal(want) {
+		t.Errorf("got event time %s, want ts %s", data.Time, want)
+	}
+	if data.Timestamp != 1766779200 {
+		t.Errorf("got ts %f, want it unchanged", data.Timestamp)
+	}
+	if skew, _ := data.ClockSkew(); skew != -700*time.Millisecond {
+		t.Errorf("got clock skew %s, want -700ms", skew)
+	}
+}
+
+func TestResolveTimeFallsBackToLokiTime(t *testing.T) {
+	data := GameData{}
+	data.resolveTime("2025-12-26T20:00:00.25Z")
+
+	if want := time.Date(2025, 12, 26, 20, 0, 0, 250e6, time.UTC); !data.Time.Equal(want) {
+		t.Errorf("got event time %s, want the Loki time %s", data.Time, want)
+	}
+	if math.Abs(data.Timestamp-1766779200.25) > 1e-6 {
+		t.Errorf("got ts %f, want 1766779200.25", data.Timestamp)
+	}
+}
+
+// A bet, its win and the next bet logged within the same whole second and
+// step are reconciled in the order Loki recorded them, not bets first
+func TestWalletOrdersSameSecondByLokiTime(t *testing.T) {
+	lines := []struct {
+		data    GameData
+		logTime string
+	}{
+		{GameData{Message: "SendBet", RoundID: "r2", Bet: 50, Balance: 1050}, "2025-12-26T20:00:00.900Z"},
+		{GameData{Message: "SendWin", RoundID: "r1", Win: 200, Balance: 1100}, "2025-12-26T20:00:00.600Z"},
+		{GameData{Message: "SendBet", RoundID: "r1", Bet: 100, Balance: 900}, "2025-12-26T20:00:00.300Z"},
+	}
+
+	ledger := newWalletLedger()
+	for _, line := range lines {
+		data := line.data
+		data.PlayerID = "p1"
+		data.Timestamp = 1766779200
+		data.resolveTime(line.logTime)
+		ledger.add(data)
+	}
+
+	summary, events, _ := ledger.reconcile(minorUnitOverrides{}.currency("NGN"), &timeZones{Default: time.UTC})
+	if summary.Breaks != 0 {
+		t.Errorf("got %d balance breaks, want none: %+v", summary.Breaks, events)
+	}
+}
+
+func TestReportBuilderKeepsSubSecondTimes(t *testing.T) {
+	b := newReportBuilder(nil, bucketing{Size: bucketSize(time.Hour)}, testLocale())
+	start := time.Date(2025, 12, 26, 20, 0, 0, 250e6, time.UTC)
+	for _, data := range []GameData{
+		{Message: "SendBet", PlayerID: "p1", Bet: 500, Time: start},
+		// No ts and no Loki time
+		{Message: "PlayerConnected", PlayerID: "p1"},
+		{Message: "SendBet", PlayerID: "p1", Bet: 100, Time: start.Add(time.Second)},
+	} {
+		b.aggregate(data)
+	}
+
+	if !b.minTime.Equal(start) || !b.maxTime.Equal(start.Add(time.Second)) {
+		t.Errorf("got period %s - %s, want %s - %s", b.minTime, b.maxTime, start, start.Add(time.Second))
+	}
+	if got, want := b.report.PlayerStats["p1"].TopBets[0].Time, "2025-12-26T20:00:00.250Z"; got != want {
+		t.Errorf("got top bet time %s, want %s", got, want)
+	}
+}
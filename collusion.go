@@ -0,0 +1,484 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// EventCollusion flags a player belonging to a cluster of linked accounts
+const EventCollusion EventType = "Linked Accounts"
+
+// LinkKind names the evidence connecting two players
+type LinkKind string
+
+const (
+	LinkSharedRoom        LinkKind = "shared_room"
+	LinkSharedHost        LinkKind = "shared_host"
+	LinkSynchronousBets   LinkKind = "synchronous_bets"
+	LinkIdenticalSequence LinkKind = "identical_bet_sequence"
+)
+
+// Definitions used to link players
+const (
+	// Rooms and hosts shared by more players than maxSharedGroup are
+	// public and do not link their players
+	maxSharedGroup = 10
+
+	// Bets of two players within syncWindowSec are synchronous. A pair is
+	// linked after minSyncBets synchronous bets of the less active player,
+	// making up minSyncShare of its bets while both played and at least
+	// minSyncZ standard deviations more than the bet rate of the other
+	// player produces by chance.
+	syncWindowSec = 1.0
+	minSyncBets   = 10
+	minSyncShare  = 0.5
+	minSyncZ      = 4
+
+	// Players are linked by a common run of stakes with at least two
+	// different amounts. The run must be long enough that two independent
+	// players with the same stake frequencies share one with a chance
+	// below maxSequenceChance, and at least minSequenceLength stakes.
+	minSequenceLength = 10
+	maxSequenceChance = 0.01
+)
+
+// PlayerLink is one piece of evidence connecting two players
+type PlayerLink struct {
+	Players  [2]string `json:"players"`
+	Kind     LinkKind  `json:"kind"`
+	Evidence string    `json:"evidence"`
+}
+
+// PlayerCluster is a group of players connected by links, with their
+// combined activity
+type PlayerCluster struct {
+	ID             int          `json:"id"`
+	Players        []string     `json:"players"`
+	Links          []PlayerLink `json:"links"`
+	TotalBets      int          `json:"total_bets"`
+	TotalBetAmount int64        `json:"total_bet_amount"`
+	TotalWinAmount int64        `json:"total_win_amount"`
+	NetResult      int64        `json:"net_result"`
+}
+
+// Kinds returns the distinct kinds of the cluster's links
+func (c PlayerCluster) Kinds() []LinkKind {
+	seen := make(map[LinkKind]bool)
+	var kinds []LinkKind
+	for _, link := range c.Links {
+		if !seen[link.Kind] {
+			seen[link.Kind] = true
+			kinds = append(kinds, link.Kind)
+		}
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+// playerLinker collects the rooms and hosts of every player while data is
+// streamed
+type playerLinker struct {
+	rooms map[string]map[string]bool
+	hosts map[string]map[string]bool
+}
+
+func newPlayerLinker() *playerLinker {
+	return &playerLinker{
+		rooms: make(map[string]map[string]bool),
+		hosts: make(map[string]map[string]bool),
+	}
+}
+
+func (l *playerLinker) add(data GameData) {
+	if data.PlayerID == "" {
+		return
+	}
+	if data.RoomID != "" {
+		addMember(l.rooms, data.RoomID, data.PlayerID)
+	}
+	if data.Hostname != "" && data.OperatorID != "" {
+		addMember(l.hosts, data.OperatorID+"@"+data.Hostname, data.PlayerID)
+	}
+}
+
+func addMember(groups map[string]map[string]bool, key, playerID string) {
+	if groups[key] == nil {
+		groups[key] = make(map[string]bool)
+	}
+	groups[key][playerID] = true
+}
+
+// linkSet collects links by player pair and kind, so repeated evidence
+// extends one link
+type linkSet map[PlayerLink][]string
+
+func (s linkSet) add(a, b string, kind LinkKind, evidence string) {
+	if a > b {
+		a, b = b, a
+	}
+	key := PlayerLink{Players: [2]string{a, b}, Kind: kind}
+	s[key] = append(s[key], evidence)
+}
+
+// cluster links players by every kind of evidence and returns the groups
+// of two or more players, the highest combined net result first. Bet
+// timestamps must be sorted.
+func (l *playerLinker) cluster(betTimestamps map[string][]float64, rounds []*Round, players map[string]PlayerStat, currency currencyFormat) []PlayerCluster {
+	links := make(linkSet)
+	linkGroups(links, l.rooms, LinkSharedRoom, "room ")
+	linkSynchronous(links, betTimestamps)
+	linkSequences(links, rounds, currency)
+
+	// A host is the game server the events were logged on, shared by
+	// unrelated players, so it only corroborates pairs linked otherwise
+	linked := make(map[[2]string]bool)
+	for link := range links {
+		linked[link.Players] = true
+	}
+	hostLinks := make(linkSet)
+	linkGroups(hostLinks, l.hosts, LinkSharedHost, "operator@host ")
+	for link, evidence := range hostLinks {
+		if linked[link.Players] {
+			links[link] = evidence
+		}
+	}
+
+	// Union the linked players, the smallest ID of a group is its root
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(id string) string {
+		if p, ok := parent[id]; ok && p != id {
+			root := find(p)
+			parent[id] = root
+			return root
+		}
+		parent[id] = id
+		return id
+	}
+	for link := range links {
+		a, b := find(link.Players[0]), find(link.Players[1])
+		if a > b {
+			a, b = b, a
+		}
+		parent[b] = a
+	}
+
+	groups := make(map[string]*PlayerCluster)
+	for id := range parent {
+		root := find(id)
+		if groups[root] == nil {
+			groups[root] = &PlayerCluster{}
+		}
+		groups[root].Players = append(groups[root].Players, id)
+	}
+	for link, evidence := range links {
+		link.Evidence = strings.Join(evidence, ", ")
+		cluster := groups[find(link.Players[0])]
+		cluster.Links = append(cluster.Links, link)
+	}
+
+	clusters := make([]PlayerCluster, 0, len(groups))
+	for _, cluster := range groups {
+		sort.Strings(cluster.Players)
+		sort.Slice(cluster.Links, func(i, j int) bool {
+			a, b := cluster.Links[i], cluster.Links[j]
+			if a.Players != b.Players {
+				return a.Players[0] < b.Players[0] || a.Players[0] == b.Players[0] && a.Players[1] < b.Players[1]
+			}
+			return a.Kind < b.Kind
+		})
+		for _, playerID := range cluster.Players {
+			stat := players[playerID]
+			cluster.TotalBets += stat.TotalBets
+			cluster.TotalBetAmount += stat.TotalBetAmount
+			cluster.TotalWinAmount += stat.TotalWinAmount
+		}
+		cluster.NetResult = cluster.TotalWinAmount - cluster.TotalBetAmount
+		clusters = append(clusters, *cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].NetResult != clusters[j].NetResult {
+			return clusters[i].NetResult > clusters[j].NetResult
+		}
+		return clusters[i].Players[0] < clusters[j].Players[0]
+	})
+	for i := range clusters {
+		clusters[i].ID = i + 1
+	}
+	return clusters
+}
+
+// linkGroups links every pair of players sharing a group that is not
+// public
+func linkGroups(links linkSet, groups map[string]map[string]bool, kind LinkKind, label string) {
+	for _, key := range sortedKeys(groups) {
+		members := sortedKeys(groups[key])
+		if len(members) < 2 || len(members) > maxSharedGroup {
+			continue
+		}
+		for i, a := range members {
+			for _, b := range members[i+1:] {
+				links.add(a, b, kind, label+key)
+			}
+		}
+	}
+}
+
+// linkSynchronous links players who repeatedly bet within syncWindowSec of
+// each other more often than chance explains
+func linkSynchronous(links linkSet, betTimestamps map[string][]float64) {
+	type bet struct {
+		ts       float64
+		playerID string
+	}
+	var bets []bet
+	for playerID, timestamps := range betTimestamps {
+		for _, ts := range timestamps {
+			bets = append(bets, bet{ts, playerID})
+		}
+	}
+	sort.Slice(bets, func(i, j int) bool { return bets[i].ts < bets[j].ts })
+
+	// Candidates are the pairs with at least one bet each within the window
+	candidates := make(map[[2]string]bool)
+	for i, first := range bets {
+		for _, second := range bets[i+1:] {
+			if second.ts-first.ts > syncWindowSec {
+				break
+			}
+			if second.playerID == first.playerID {
+				continue
+			}
+			pair := [2]string{first.playerID, second.playerID}
+			if pair[0] > pair[1] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			candidates[pair] = true
+		}
+	}
+
+	for pair := range candidates {
+		if evidence, ok := testSynchronous(betTimestamps[pair[0]], betTimestamps[pair[1]]); ok {
+			links.add(pair[0], pair[1], LinkSynchronousBets, evidence)
+		}
+	}
+}
+
+// testSynchronous counts the bets of the less active player with a bet of
+// the other within syncWindowSec, while both played, and compares them with
+// the share a Poisson process at the other player's bet rate would reach.
+// Timestamps must be sorted.
+func testSynchronous(a, b []float64) (string, bool) {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	start, end := math.Max(a[0], b[0]), math.Min(a[len(a)-1], b[len(b)-1])
+	if end <= start {
+		return "", false
+	}
+
+	var bets, synchronous, j int
+	for _, ts := range a {
+		if ts < start || ts > end {
+			continue
+		}
+		bets++
+		for j < len(b) && b[j] < ts-syncWindowSec {
+			j++
+		}
+		if j < len(b) && b[j] <= ts+syncWindowSec {
+			synchronous++
+		}
+	}
+
+	var otherBets int
+	for _, ts := range b {
+		if ts >= start && ts <= end {
+			otherBets++
+		}
+	}
+	chance := 1 - math.Exp(-2*syncWindowSec*float64(otherBets)/(end-start))
+
+	if synchronous < minSyncBets || float64(synchronous) < minSyncShare*float64(bets) || chance >= 1 {
+		return "", false
+	}
+	z := (float64(synchronous) - chance*float64(bets)) / math.Sqrt(float64(bets)*chance*(1-chance))
+	if z < minSyncZ {
+		return "", false
+	}
+	return fmt.Sprintf("%d of %d bets within %gs, %.0f%% expected by chance", synchronous, bets, syncWindowSec, chance*100), true
+}
+
+// linkSequences links players who played the same run of stakes, longer
+// than their stake frequencies explain
+func linkSequences(links linkSet, rounds []*Round, currency currencyFormat) {
+	stakes := make(map[string][]int64)
+	for _, round := range rounds {
+		if round.TotalBet > 0 {
+			stakes[round.PlayerID] = append(stakes[round.PlayerID], round.TotalBet)
+		}
+	}
+
+	// Candidates are the pairs sharing a run of minSequenceLength stakes
+	sequences := make(map[string]map[string]bool)
+	for playerID, playerStakes := range stakes {
+		for i := 0; i+minSequenceLength <= len(playerStakes); i++ {
+			run := playerStakes[i : i+minSequenceLength]
+			if !hasDistinctAmounts(run) {
+				continue
+			}
+			key := fmt.Sprint(run)
+			if sequences[key] == nil {
+				sequences[key] = make(map[string]bool)
+			}
+			sequences[key][playerID] = true
+		}
+	}
+
+	candidates := make(map[[2]string]bool)
+	for _, players := range sequences {
+		members := sortedKeys(players)
+		if len(members) < 2 || len(members) > maxSharedGroup {
+			continue
+		}
+		for i, a := range members {
+			for _, b := range members[i+1:] {
+				candidates[[2]string{a, b}] = true
+			}
+		}
+	}
+
+	for pair := range candidates {
+		a, b := stakes[pair[0]], stakes[pair[1]]
+		length := requiredRunLength(a, b)
+		if example, count := commonRuns(a, b, length); count > 0 {
+			links.add(pair[0], pair[1], LinkIdenticalSequence,
+				fmt.Sprintf("%d shared %d-bet sequences such as %s", count, length, formatStakes(example, currency)))
+		}
+	}
+}
+
+// requiredRunLength returns the length of a common run of stakes that two
+// independent players with these stakes share with a chance below
+// maxSequenceChance. Two stakes agree by chance with probability q, the
+// sum over the amounts of the product of their frequencies, and a run can
+// start at about len(a) * len(b) pairs of positions.
+func requiredRunLength(a, b []int64) int {
+	frequencies := make(map[int64]float64)
+	for _, stake := range a {
+		frequencies[stake] += 1 / float64(len(a))
+	}
+	var q float64
+	for _, stake := range b {
+		q += frequencies[stake] / float64(len(b))
+	}
+	if q <= 0 || q >= 1 {
+		return max(len(a), len(b)) + 1
+	}
+
+	length := math.Log(maxSequenceChance/(float64(len(a))*float64(len(b)))) / math.Log(q)
+	return max(minSequenceLength, int(math.Ceil(length)))
+}
+
+// commonRuns counts the runs of length stakes of b, with at least two
+// different amounts, that a also played, and returns the first of them
+func commonRuns(a, b []int64, length int) ([]int64, int) {
+	played := make(map[string]bool)
+	for i := 0; i+length <= len(a); i++ {
+		if run := a[i : i+length]; hasDistinctAmounts(run) {
+			played[fmt.Sprint(run)] = true
+		}
+	}
+
+	var example []int64
+	count := 0
+	for i := 0; i+length <= len(b); i++ {
+		if run := b[i : i+length]; played[fmt.Sprint(run)] {
+			if example == nil {
+				example = run
+			}
+			count++
+		}
+	}
+	return example, count
+}
+
+func hasDistinctAmounts(amounts []int64) bool {
+	for _, amount := range amounts[1:] {
+		if amount != amounts[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func printClusters(w io.Writer, clusters []PlayerCluster, currency currencyFormat, limit int) {
+	fmt.Fprintln(w, "\n🕸️ LINKED ACCOUNTS:")
+
+	count := min(limit, len(clusters))
+	for _, cluster := range clusters[:count] {
+		fmt.Fprintf(w, "Cluster #%d: %s\n", cluster.ID, strings.Join(cluster.Players, ", "))
+		fmt.Fprintf(w, "├─ Bets: %d, Volume: %s, Combined Net: %s\n",
+			cluster.TotalBets, formatMoney(cluster.TotalBetAmount, currency), formatMoney(cluster.NetResult, currency))
+		for i, link := range cluster.Links {
+			branch := "├─"
+			if i == len(cluster.Links)-1 {
+				branch = "└─"
+			}
+			fmt.Fprintf(w, "%s %s ↔ %s: %s (%s)\n", branch, link.Players[0], link.Players[1], link.Kind, link.Evidence)
+		}
+	}
+	if len(clusters) > count {
+		fmt.Fprintf(w, "   ... and %d more clusters\n", len(clusters)-count)
+	}
+}
+
+// collusionDetector flags every player of a cluster of at least
+// min_accounts players whose links are of at least min_link_kinds kinds
+type collusionDetector struct{}
+
+func (collusionDetector) Rule() Rule {
+	return Rule{
+		Name:     "linked_accounts",
+		Enabled:  true,
+		Severity: SeverityHigh,
+		Scope:    ScopePlayer,
+		Params:   map[string]float64{"min_accounts": 2, "min_link_kinds": 1},
+	}
+}
+
+func (collusionDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (collusionDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	var events []SuspiciousEvent
+
+	for _, cluster := range data.report.Clusters {
+		kinds := cluster.Kinds()
+		if len(cluster.Players) < int(rule.param("min_accounts")) || len(kinds) < int(rule.param("min_link_kinds")) {
+			continue
+		}
+
+		names := make([]string, len(kinds))
+		for i, kind := range kinds {
+			names[i] = string(kind)
+		}
+		for _, playerID := range cluster.Players {
+			events = append(events, SuspiciousEvent{
+				Type:        EventCollusion,
+				Description: fmt.Sprintf("Player is linked to %d other accounts (cluster #%d)", len(cluster.Players)-1, cluster.ID),
+				PlayerID:    playerID,
+				Amount:      cluster.NetResult,
+				Details: fmt.Sprintf("Cluster %s linked by %s, combined net %s",
+					strings.Join(cluster.Players, ", "), strings.Join(names, ", "), formatMoney(cluster.NetResult, data.report.money())),
+			})
+		}
+	}
+
+	return events
+}
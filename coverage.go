@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// SourceCoverage describes the time range covered by one input file or
+// Loki query window
+type SourceCoverage struct {
+	Source    string    `json:"source"`
+	Entries   int       `json:"entries"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Truncated bool      `json:"truncated"`
+}
+
+// CoverageGap is a period between two neighbouring sources without data
+type CoverageGap struct {
+	After  string    `json:"after"`
+	Before string    `json:"before"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+type CoverageReport struct {
+	Sources    []SourceCoverage `json:"sources"`
+	Gaps       []CoverageGap    `json:"gaps"`
+	Incomplete bool             `json:"incomplete"`
+}
+
+// coverageTracker records the entry count and time range of a source
+// while it is streamed
+type coverageTracker struct {
+	coverage SourceCoverage
+}
+
+func (t *coverageTracker) add(entry LogEntry, data GameData, hasData bool) {
+	t.coverage.Entries++
+
+	ts, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+	if err != nil {
+		if !hasData || data.Time.IsZero() {
+			return
+		}
+		ts = data.Time
+	}
+
+	if t.coverage.Start.IsZero() || ts.Before(t.coverage.Start) {
+		t.coverage.Start = ts
+	}
+	if ts.After(t.coverage.End) {
+		t.coverage.End = ts
+	}
+}
+
+// buildCoverage marks sources holding exactly exportLimit entries as
+// truncated and lists gaps longer than maxGap between neighbouring files
+func buildCoverage(files []SourceCoverage, exportLimit int, maxGap time.Duration) *CoverageReport {
+	coverage := &CoverageReport{}
+
+	for _, file := range files {
+		if exportLimit > 0 && file.Entries == exportLimit {
+			file.Truncated = true
+		}
+		coverage.Sources = append(coverage.Sources, file)
+	}
+
+	sort.SliceStable(coverage.Sources, func(i, j int) bool {
+		return coverage.Sources[i].Start.Before(coverage.Sources[j].Start)
+	})
+
+	var (
+		covered     time.Time
+		coveredFrom string
+	)
+	for _, source := range coverage.Sources {
+		if source.Truncated {
+			coverage.Incomplete = true
+		}
+		if source.Entries == 0 || source.Start.IsZero() {
+			continue
+		}
+
+		if !covered.IsZero() && source.Start.Sub(covered) > maxGap {
+			coverage.Gaps = append(coverage.Gaps, CoverageGap{
+				After:  coveredFrom,
+				Before: source.Source,
+				Start:  covered,
+				End:    source.Start,
+			})
+			coverage.Incomplete = true
+		}
+
+		if source.End.After(covered) {
+			covered = source.End
+			coveredFrom = source.Source
+		}
+	}
+
+	return coverage
+}
+
+func printCoverage(w io.Writer, coverage *CoverageReport, zones *timeZones) {
+	fmt.Fprintln(w, "\n🧩 DATA COVERAGE:")
+	for _, source := range coverage.Sources {
+		flag := ""
+		if source.Truncated {
+			flag = " ⚠️  at export limit - likely truncated"
+		}
+		if source.Entries == 0 {
+			fmt.Fprintf(w, "├─ %s: no entries\n", source.Source)
+			continue
+		}
+		fmt.Fprintf(w, "├─ %s: %d entries, %s - %s%s\n", source.Source, source.Entries,
+			source.Start.In(zones.Default).Format(timeLayout), source.End.In(zones.Default).Format(timeLayout), flag)
+	}
+
+	for _, gap := range coverage.Gaps {
+		fmt.Fprintf(w, "├─ ⚠️  Gap of %s between %s and %s (%s - %s)\n",
+			gap.End.Sub(gap.Start).Round(time.Second), gap.After, gap.Before,
+			gap.Start.In(zones.Default).Format(timeLayout), gap.End.In(zones.Default).Format(timeLayout))
+	}
+
+	if coverage.Incomplete {
+		fmt.Fprintf(w, "└─ ⚠️  Coverage is INCOMPLETE - re-export the flagged periods with smaller time ranges\n")
+	} else {
+		fmt.Fprintf(w, "└─ ✅ No truncated files or gaps detected\n")
+	}
+}
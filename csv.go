@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeCSVReport writes the player, game, operator, platform, activity
+// timeline, suspicious activity and linked account tables as separate CSV
+// files into dir, and the heatmap when enabled. Mixed-currency data is
+// exported in native currencies, one row per currency.
+func writeCSVReport(dir string, report Report) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating csv directory: %w", err)
+	}
+
+	reports := currencyReports(report)
+	tables := []struct {
+		name string
+		rows [][]string
+	}{
+		{"players.csv", playerRows(reports)},
+		{"games.csv", gameRows(reports)},
+		{"operators.csv", segmentRows(reports, "operator_id", func(r Report) map[string]SegmentStat { return r.OperatorStats })},
+		{"platforms.csv", segmentRows(reports, "platform_id", func(r Report) map[string]SegmentStat { return r.PlatformStats })},
+		{"timeline.csv", timelineRows(reports)},
+		{"suspicious.csv", suspiciousRows(reports)},
+		{"clusters.csv", clusterRows(reports)},
+	}
+	if reports[0].Heatmap != nil {
+		tables = append(tables, struct {
+			name string
+			rows [][]string
+		}{"heatmap.csv", heatmapRows(reports)})
+	}
+
+	for _, table := range tables {
+		if err := writeCSVFile(filepath.Join(dir, table.name), table.rows); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(progress, "\n📝 CSV tables written to %s\n", dir)
+	return nil
+}
+
+func writeCSVFile(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating csv: %w", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", path, err)
+	}
+	return nil
+}
+
+func playerRows(reports []Report) [][]string {
+	rows := [][]string{{
+		"player_id", "currency", "total_bets", "total_wins",
+		"total_bet_amount_minor", "total_bet_amount",
+		"total_win_amount_minor", "total_win_amount",
+		"net_result_minor", "net_result",
+		"last_balance_minor", "last_balance",
+		"rtp_percentage", "min_bet_interval_sec", "max_spins_per_minute",
+		"bet_interval_mean_sec", "bet_interval_stddev_sec", "bet_interval_cv", "bet_interval_entropy_bits", "bet_periodicity",
+		"balance_breaks", "unexplained_balance_change_minor", "unexplained_balance_change",
+		"rtp_test_rounds", "expected_rtp_percentage", "rtp_z_score", "rtp_p_value",
+		"hit_rate_percentage", "max_multiplier", "expected_hit_rate_percentage", "hit_rate_z_score",
+		"doubled_after_loss", "losses_followed", "longest_doubling_chain", "step_up_big_wins", "step_ups", "repeated_stake_cycles",
+		"risk_score", "risk_severity", "max_timestamp_skew_sec",
+	}}
+
+	for _, report := range reports {
+		rows = append(rows, reportPlayerRows(report)...)
+	}
+
+	return rows
+}
+
+func reportPlayerRows(report Report) [][]string {
+	var rows [][]string
+
+	players := make([]PlayerStat, 0, len(report.PlayerStats))
+	for _, stat := range report.PlayerStats {
+		players = append(players, stat)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].TotalBetAmount > players[j].TotalBetAmount
+	})
+
+	for _, p := range players {
+		row := []string{p.PlayerID, report.Currency, strconv.Itoa(p.TotalBets), strconv.Itoa(p.TotalWins)}
+		row = append(row, amountColumns(p.TotalBetAmount, report.money())...)
+		row = append(row, amountColumns(p.TotalWinAmount, report.money())...)
+		row = append(row, amountColumns(p.NetResult, report.money())...)
+		row = append(row, amountColumns(p.LastBalance, report.money())...)
+		row = append(row,
+			formatFloat(p.RTP),
+			formatFloat(p.MinBetIntervalSec),
+			strconv.Itoa(p.MaxSpinsPerMinute),
+		)
+		if timing := p.BetTiming; timing != nil {
+			row = append(row, formatFloat(timing.MeanSec), formatFloat(timing.StdDevSec),
+				formatFloat(timing.CV), formatFloat(timing.EntropyBits), formatFloat(timing.Periodicity))
+		} else {
+			row = append(row, "", "", "", "", "")
+		}
+		row = append(row, strconv.Itoa(p.BalanceBreaks))
+		row = append(row, amountColumns(p.UnexplainedBalance, report.money())...)
+		if test := p.RTPTest; test != nil {
+			row = append(row, strconv.Itoa(test.Rounds), formatFloat(test.ExpectedRTP),
+				formatFloat(test.ZScore), strconv.FormatFloat(test.PValue, 'g', 4, 64))
+		} else {
+			row = append(row, "", "", "", "")
+		}
+		if hits := p.Hits; hits != nil {
+			row = append(row, formatFloat(hits.HitRate), formatFloat(hits.MaxMultiplier))
+		} else {
+			row = append(row, "", "")
+		}
+		if test := p.HitTest; test != nil {
+			row = append(row, formatFloat(test.ExpectedHitRate), formatFloat(test.ZScore))
+		} else {
+			row = append(row, "", "")
+		}
+		if patterns := p.BetPatterns; patterns != nil {
+			row = append(row, strconv.Itoa(patterns.DoubledAfterLoss), strconv.Itoa(patterns.LossesFollowed),
+				strconv.Itoa(patterns.LongestDoublingChain), strconv.Itoa(patterns.StepUpBigWins),
+				strconv.Itoa(patterns.StepUps), strconv.Itoa(patterns.RepeatCycles))
+		} else {
+			row = append(row, "", "", "", "", "", "")
+		}
+		row = append(row, formatFloat(p.RiskScore), string(p.RiskSeverity))
+		if skew := p.TimestampSkew; skew != nil {
+			row = append(row, strconv.FormatFloat(skew.MaxSkewSec, 'f', 3, 64))
+		} else {
+			row = append(row, "")
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func gameRows(reports []Report) [][]string {
+	rows := [][]string{{
+		"game_id", "currency", "total_bets", "total_wins",
+		"total_bet_amount_minor", "total_bet_amount",
+		"total_win_amount_minor", "total_win_amount",
+		"rtp_percentage", "unique_players",
+		"hit_rate_percentage", "max_multiplier",
+	}}
+	for _, bucket := range newHitStats().Multipliers {
+		rows[0] = append(rows[0], "wins_"+bucket.Label)
+	}
+
+	for _, report := range reports {
+		gameIDs := make([]string, 0, len(report.GameStats))
+		for gameID := range report.GameStats {
+			gameIDs = append(gameIDs, gameID)
+		}
+		sort.Strings(gameIDs)
+
+		for _, gameID := range gameIDs {
+			g := report.GameStats[gameID]
+			row := []string{gameID, report.Currency, strconv.Itoa(g.TotalBets), strconv.Itoa(g.TotalWins)}
+			row = append(row, amountColumns(g.TotalBetAmount, report.money())...)
+			row = append(row, amountColumns(g.TotalWinAmount, report.money())...)
+			row = append(row, formatFloat(g.RTP), strconv.Itoa(g.Players))
+			if hits := g.Hits; hits != nil {
+				row = append(row, formatFloat(hits.HitRate), formatFloat(hits.MaxMultiplier))
+				for _, bucket := range hits.Multipliers {
+					row = append(row, strconv.Itoa(bucket.Count))
+				}
+			} else {
+				row = append(row, make([]string, len(rows[0])-len(row))...)
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+func segmentRows(reports []Report, idColumn string, segments func(Report) map[string]SegmentStat) [][]string {
+	rows := [][]string{{
+		idColumn, "currency", "total_bets", "total_wins",
+		"total_bet_amount_minor", "total_bet_amount",
+		"total_win_amount_minor", "total_win_amount",
+		"net_result_minor", "net_result",
+		"rtp_percentage", "players", "flagged_events", "flagged_players",
+	}}
+
+	for _, report := range reports {
+		for _, s := range sortedSegments(segments(report)) {
+			row := []string{s.ID, report.Currency, strconv.Itoa(s.TotalBets), strconv.Itoa(s.TotalWins)}
+			row = append(row, amountColumns(s.TotalBetAmount, report.money())...)
+			row = append(row, amountColumns(s.TotalWinAmount, report.money())...)
+			row = append(row, amountColumns(s.NetResult, report.money())...)
+			row = append(row, formatFloat(s.RTP), strconv.Itoa(s.Players), strconv.Itoa(s.FlaggedEvents), strconv.Itoa(s.FlaggedPlayers))
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+var activityHeader = []string{
+	"total_bets", "total_wins",
+	"total_bet_amount_minor", "total_bet_amount",
+	"total_win_amount_minor", "total_win_amount",
+	"rtp_percentage", "active_players",
+}
+
+func activityColumns(a Activity, currency currencyFormat) []string {
+	row := []string{strconv.Itoa(a.TotalBets), strconv.Itoa(a.TotalWins)}
+	row = append(row, amountColumns(a.TotalBetAmount, currency)...)
+	row = append(row, amountColumns(a.TotalWinAmount, currency)...)
+	return append(row, formatFloat(a.RTP), strconv.Itoa(a.ActivePlayers))
+}
+
+func timelineRows(reports []Report) [][]string {
+	rows := [][]string{append([]string{"start", "end", "currency"}, activityHeader...)}
+
+	for _, report := range reports {
+		for _, t := range report.TimeStats {
+			rows = append(rows, append([]string{t.Start, t.End, report.Currency}, activityColumns(t.Activity, report.money())...))
+		}
+	}
+
+	return rows
+}
+
+// heatmapRows lists the hours of the week with activity, Monday first
+func heatmapRows(reports []Report) [][]string {
+	rows := [][]string{append([]string{"weekday", "hour", "currency"}, activityHeader...)}
+
+	for _, report := range reports {
+		if report.Heatmap == nil {
+			continue
+		}
+		for _, day := range weekdaysFromMonday {
+			for hour, activity := range report.Heatmap.Days[day] {
+				if activity.ActivePlayers == 0 {
+					continue
+				}
+				row := []string{day.String(), fmt.Sprintf("%02d:00", hour), report.Currency}
+				rows = append(rows, append(row, activityColumns(activity, report.money())...))
+			}
+		}
+	}
+
+	return rows
+}
+
+func suspiciousRows(reports []Report) [][]string {
+	rows := [][]string{{"type", "rule", "severity", "score", "currency", "player_id", "game_id", "operator_id", "platform_id", "round_id", "timestamp", "end_timestamp", "amount_minor", "amount", "description", "details"}}
+
+	for _, report := range reports {
+		for _, e := range report.SuspiciousEvents {
+			row := []string{string(e.Type), e.Rule, string(e.Severity), formatFloat(e.Score), report.Currency, e.PlayerID, e.GameID, e.OperatorID, e.PlatformID, e.RoundID, e.Timestamp, e.EndTimestamp}
+			row = append(row, amountColumns(e.Amount, report.money())...)
+			row = append(row, e.Description, e.Details)
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+func clusterRows(reports []Report) [][]string {
+	rows := [][]string{{
+		"cluster_id", "currency", "players", "link_kinds", "total_bets",
+		"total_bet_amount_minor", "total_bet_amount",
+		"total_win_amount_minor", "total_win_amount",
+		"net_result_minor", "net_result", "links",
+	}}
+
+	for _, report := range reports {
+		for _, c := range report.Clusters {
+			kinds := make([]string, 0, len(c.Links))
+			for _, kind := range c.Kinds() {
+				kinds = append(kinds, string(kind))
+			}
+			links := make([]string, 0, len(c.Links))
+			for _, link := range c.Links {
+				links = append(links, fmt.Sprintf("%s-%s %s: %s", link.Players[0], link.Players[1], link.Kind, link.Evidence))
+			}
+
+			row := []string{strconv.Itoa(c.ID), report.Currency, strings.Join(c.Players, ";"), strings.Join(kinds, ";"), strconv.Itoa(c.TotalBets)}
+			row = append(row, amountColumns(c.TotalBetAmount, report.money())...)
+			row = append(row, amountColumns(c.TotalWinAmount, report.money())...)
+			row = append(row, amountColumns(c.NetResult, report.money())...)
+			row = append(row, strings.Join(links, "; "))
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// amountColumns returns the raw minor-unit amount and the same amount in
+// major units
+func amountColumns(amount int64, currency currencyFormat) []string {
+	return []string{strconv.FormatInt(amount, 10), formatMajorUnits(amount, currency.exponent)}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
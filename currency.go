@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// unknownCurrency groups events logged without a currency
+const unknownCurrency = "UNKNOWN"
+
+// exchangeRates converts amounts into a single reporting currency. Each
+// rate is the value of one major unit of the currency in the base currency.
+type exchangeRates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func loadExchangeRates(path, reportingCurrency string) (*exchangeRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rates file: %w", err)
+	}
+
+	var rates exchangeRates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("unmarshaling rates file: %w", err)
+	}
+
+	rates.Base = strings.ToUpper(rates.Base)
+	normalized := make(map[string]float64, len(rates.Rates)+1)
+	for currency, rate := range rates.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("rates file: rate for %s must be positive", currency)
+		}
+		normalized[strings.ToUpper(currency)] = rate
+	}
+	rates.Rates = normalized
+
+	if reportingCurrency != "" {
+		reportingCurrency = strings.ToUpper(reportingCurrency)
+		if reportingCurrency != rates.Base {
+			// Rebase so that the reporting currency has a rate of 1
+			rate, ok := rates.Rates[reportingCurrency]
+			if !ok {
+				return nil, fmt.Errorf("rates file has no rate for reporting currency %s", reportingCurrency)
+			}
+			for currency := range rates.Rates {
+				rates.Rates[currency] /= rate
+			}
+			if rates.Base != "" {
+				rates.Rates[rates.Base] = 1 / rate
+			}
+			rates.Base = reportingCurrency
+		}
+	}
+
+	if rates.Base == "" {
+		return nil, fmt.Errorf("rates file: base currency is required")
+	}
+	rates.Rates[rates.Base] = 1
+
+	return &rates, nil
+}
+
+// convert returns the event with all amounts expressed in the base currency
+func (r *exchangeRates) convert(data GameData, minorUnits minorUnitOverrides) (GameData, error) {
+	rate, ok := r.Rates[strings.ToUpper(data.Currency)]
+	if !ok {
+		return GameData{}, fmt.Errorf("no exchange rate for currency %q (player %s)", data.Currency, data.PlayerID)
+	}
+
+	// Rates apply to major units, amounts are in minor units
+	rate *= math.Pow10(minorUnits.exponent(r.Base) - minorUnits.exponent(data.Currency))
+
+	data.Bet = convertAmount(data.Bet, rate)
+	data.Win = convertAmount(data.Win, rate)
+	data.Balance = convertAmount(data.Balance, rate)
+	data.Currency = r.Base
+	return data, nil
+}
+
+func convertAmount(amount int64, rate float64) int64 {
+	return int64(math.Round(float64(amount) * rate))
+}
+
+// analyzer removes duplicate transactions and aggregates the remaining
+// events per currency, plus a combined report in the reporting currency
+// when exchange rates are configured
+type analyzer struct {
+	rules    *ruleSet
+	rates    *exchangeRates
+	buckets  bucketing
+	locale   locale
+	builders map[string]*reportBuilder
+
+	// converted aggregates every event converted to rates.Base
+	converted *reportBuilder
+
+	// filteredWallets limits wallet reconciliation to events read next to
+	// each other, set when a filter leaves gaps in the balance history
+	filteredWallets bool
+
+	uniqueBetIDs  map[string]bool
+	uniqueWinIDs  map[string]bool
+	duplicateBets map[string]int
+	duplicateWins map[string]int
+}
+
+func newAnalyzer(rules *ruleSet, rates *exchangeRates, buckets bucketing, loc locale) *analyzer {
+	a := &analyzer{
+		rules:         rules,
+		buckets:       buckets,
+		locale:        loc,
+		rates:         rates,
+		builders:      make(map[string]*reportBuilder),
+		uniqueBetIDs:  make(map[string]bool),
+		uniqueWinIDs:  make(map[string]bool),
+		duplicateBets: make(map[string]int),
+		duplicateWins: make(map[string]int),
+	}
+	if rates != nil {
+		a.converted = newReportBuilder(rules, buckets, loc)
+		a.converted.report.Currency = rates.Base
+		// Converted balances carry rounding differences, so wallets are
+		// only reconciled in their native currency
+		a.converted.wallets = nil
+	}
+	return a
+}
+
+// add processes a single game data entry, skipping duplicate transactions
+func (a *analyzer) add(data GameData) error {
+	data = a.locale.MinorUnits.normalize(data)
+
+	// Events like PlayerConnected carry neither a currency nor an amount;
+	// they would only make up an UNKNOWN report and cannot be converted
+	if data.Currency == "" && data.Bet == 0 && data.Win == 0 {
+		return nil
+	}
+
+	currency := strings.ToUpper(data.Currency)
+	if currency == "" {
+		currency = unknownCurrency
+	}
+
+	// Check if this bet or win ID was already processed
+	if data.Message == "SendBet" && data.BetID != "" {
+		if a.uniqueBetIDs[data.BetID] {
+			a.duplicateBets[currency]++
+			fmt.Fprintf(progress, "   ⚠️  Skipping duplicate bet ID: %s\n", data.BetID)
+			return nil // Skip duplicate bet
+		}
+		a.uniqueBetIDs[data.BetID] = true
+	} else if data.Message == "SendWin" && data.WinID != "" {
+		if a.uniqueWinIDs[data.WinID] {
+			a.duplicateWins[currency]++
+			fmt.Fprintf(progress, "   ⚠️  Skipping duplicate win ID: %s\n", data.WinID)
+			return nil // Skip duplicate win
+		}
+		a.uniqueWinIDs[data.WinID] = true
+	}
+
+	builder, ok := a.builders[currency]
+	if !ok {
+		builder = newReportBuilder(a.rules, a.buckets, a.locale)
+		builder.report.Currency = currency
+		a.builders[currency] = builder
+	}
+	builder.add(data)
+
+	if a.converted != nil {
+		converted, err := a.rates.convert(data, a.locale.MinorUnits)
+		if err != nil {
+			return err
+		}
+		a.converted.add(converted)
+	}
+
+	return nil
+}
+
+// currencies returns the currencies seen so far in alphabetical order
+func (a *analyzer) currencies() []string {
+	currencies := make([]string, 0, len(a.builders))
+	for currency := range a.builders {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// build returns a report per currency. With a single currency and no
+// exchange rates that report is returned as is; otherwise the per-currency
+// reports are attached to a combined report in the reporting currency, or
+// to an empty report when amounts cannot be combined.
+func (a *analyzer) build() Report {
+	reports := make(map[string]Report, len(a.builders))
+	totalDuplicateBets, totalDuplicateWins := 0, 0
+	for currency, builder := range a.builders {
+		builder.wallets.filtered = a.filteredWallets
+		report := builder.build()
+		report.Currency = currency
+		report.Summary.DuplicateBets = a.duplicateBets[currency]
+		report.Summary.DuplicateWins = a.duplicateWins[currency]
+		totalDuplicateBets += report.Summary.DuplicateBets
+		totalDuplicateWins += report.Summary.DuplicateWins
+		reports[currency] = report
+	}
+
+	if a.converted == nil && len(reports) == 1 {
+		for _, report := range reports {
+			return report
+		}
+	}
+
+	var report Report
+	if a.converted != nil {
+		a.converted.wallets.filtered = a.filteredWallets
+		report = a.converted.build()
+		report.Currency = a.rates.Base
+	} else {
+		report = Report{
+			PlayerStats:      make(map[string]PlayerStat),
+			GameStats:        make(map[string]GameStat),
+			SuspiciousEvents: []SuspiciousEvent{},
+			locale:           a.locale,
+		}
+	}
+	report.Summary.DuplicateBets = totalDuplicateBets
+	report.Summary.DuplicateWins = totalDuplicateWins
+	report.Currencies = reports
+
+	return report
+}
+
+// printDuplicates prints the duplicate statistics if any found
+func (a *analyzer) printDuplicates() {
+	duplicateBets, duplicateWins := 0, 0
+	for _, count := range a.duplicateBets {
+		duplicateBets += count
+	}
+	for _, count := range a.duplicateWins {
+		duplicateWins += count
+	}
+
+	if duplicateBets > 0 || duplicateWins > 0 {
+		fmt.Fprintf(progress, "\n📋 DUPLICATE DETECTION:\n")
+		if duplicateBets > 0 {
+			fmt.Fprintf(progress, "├─ Duplicate bets found and skipped: %d\n", duplicateBets)
+		}
+		if duplicateWins > 0 {
+			fmt.Fprintf(progress, "├─ Duplicate wins found and skipped: %d\n", duplicateWins)
+		}
+		fmt.Fprintf(progress, "└─ Only unique transactions included in analysis\n")
+	} else {
+		fmt.Fprintf(progress, "\n✅ DATA INTEGRITY: No duplicate transactions detected\n")
+	}
+}
+
+// currencyReports returns the native single-currency reports in
+// alphabetical order of currency
+func currencyReports(report Report) []Report {
+	if len(report.Currencies) == 0 {
+		return []Report{report}
+	}
+
+	currencies := make([]string, 0, len(report.Currencies))
+	for currency := range report.Currencies {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	reports := make([]Report, len(currencies))
+	for i, currency := range currencies {
+		reports[i] = report.Currencies[currency]
+	}
+	return reports
+}
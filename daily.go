@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DayComparison holds the change of the key figures from one day to the next
+type DayComparison struct {
+	Date              string  `json:"date"`
+	PreviousDate      string  `json:"previous_date"`
+	RTP               float64 `json:"rtp_percentage"`
+	PreviousRTP       float64 `json:"previous_rtp_percentage"`
+	BetAmount         int64   `json:"total_bet_amount"`
+	PreviousBetAmount int64   `json:"previous_total_bet_amount"`
+	BetAmountDiff     float64 `json:"bet_amount_change_percentage"`
+	Bets              int     `json:"total_bets"`
+	PreviousBets      int     `json:"previous_total_bets"`
+	Players           int     `json:"unique_players"`
+	PreviousPlayers   int     `json:"previous_unique_players"`
+}
+
+// compareDays compares every day with the previous day in the list
+func compareDays(daily []DailyReport) []DayComparison {
+	var comparisons []DayComparison
+
+	for i := 1; i < len(daily); i++ {
+		prev, cur := daily[i-1].Report.Summary, daily[i].Report.Summary
+
+		comparison := DayComparison{
+			Date:              daily[i].Date,
+			PreviousDate:      daily[i-1].Date,
+			RTP:               cur.RTP,
+			PreviousRTP:       prev.RTP,
+			BetAmount:         cur.TotalBetAmount,
+			PreviousBetAmount: prev.TotalBetAmount,
+			Bets:              cur.TotalBets,
+			PreviousBets:      prev.TotalBets,
+			Players:           cur.UniquePlayers,
+			PreviousPlayers:   prev.UniquePlayers,
+		}
+		if prev.TotalBetAmount > 0 {
+			comparison.BetAmountDiff = float64(cur.TotalBetAmount-prev.TotalBetAmount) / float64(prev.TotalBetAmount) * 100
+		}
+
+		comparisons = append(comparisons, comparison)
+	}
+
+	return comparisons
+}
+
+func printDayOverDay(w io.Writer, comparisons []DayComparison, currency currencyFormat) {
+	if len(comparisons) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\n"+strings.Repeat("=", 60))
+	fmt.Fprintln(w, "                  DAY-OVER-DAY COMPARISON")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+
+	for _, c := range comparisons {
+		fmt.Fprintf(w, "\n📅 %s vs %s:\n", c.Date, c.PreviousDate)
+		fmt.Fprintf(w, "├─ RTP: %.2f%% → %.2f%% (%+.2f pp)\n", c.PreviousRTP, c.RTP, c.RTP-c.PreviousRTP)
+		fmt.Fprintf(w, "├─ Bet Volume: %s → %s (%+.2f%%)\n",
+			formatMoney(c.PreviousBetAmount, currency), formatMoney(c.BetAmount, currency), c.BetAmountDiff)
+		fmt.Fprintf(w, "├─ Bets: %d → %d (%+d)\n", c.PreviousBets, c.Bets, c.Bets-c.PreviousBets)
+		fmt.Fprintf(w, "└─ Players: %d → %d (%+d)\n", c.PreviousPlayers, c.Players, c.Players-c.PreviousPlayers)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import "fmt"
+
+// Names of the built-in rules whose thresholds have command-line flags
+const (
+	ruleHighRTP      = "high_rtp"
+	ruleHighSpinRate = "high_spin_rate"
+)
+
+// highRTPDetector flags players, games or operators returning more than
+// max_rtp percent of the amount bet over more than min_bets bets
+type highRTPDetector struct{}
+
+func (highRTPDetector) Rule() Rule {
+	return Rule{
+		Name:     ruleHighRTP,
+		Enabled:  true,
+		Severity: SeverityHigh,
+		Scope:    ScopePlayer,
+		Params:   map[string]float64{"max_rtp": 150, "min_bets": 100},
+	}
+}
+
+func (highRTPDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer, ScopeGame, ScopeOperator}
+}
+
+func (highRTPDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	maxRTP, minBets := rule.param("max_rtp"), int(rule.param("min_bets"))
+	var events []SuspiciousEvent
+
+	switch rule.Scope {
+	case ScopePlayer:
+		for _, playerID := range sortedKeys(data.report.PlayerStats) {
+			pStat := data.report.PlayerStats[playerID]
+			if pStat.TotalBets > minBets && pStat.RTP > maxRTP {
+				events = append(events, SuspiciousEvent{
+					Type:        EventHighRTP,
+					Description: "Player has suspiciously high RTP",
+					PlayerID:    playerID,
+					Details:     fmt.Sprintf("RTP: %.2f%%, Bets: %d", pStat.RTP, pStat.TotalBets),
+				})
+			}
+		}
+	case ScopeGame:
+		for _, gameID := range sortedKeys(data.report.GameStats) {
+			gStat := data.report.GameStats[gameID]
+			if gStat.TotalBets > minBets && gStat.RTP > maxRTP {
+				events = append(events, SuspiciousEvent{
+					Type:        EventHighRTP,
+					Description: "Game has suspiciously high RTP",
+					GameID:      gameID,
+					Details:     fmt.Sprintf("RTP: %.2f%%, Bets: %d, Players: %d", gStat.RTP, gStat.TotalBets, gStat.Players),
+				})
+			}
+		}
+	case ScopeOperator:
+		for _, operatorID := range sortedKeys(data.operators) {
+			totals := data.operators[operatorID]
+			if totals.bets > minBets && totals.rtp() > maxRTP {
+				events = append(events, SuspiciousEvent{
+					Type:        EventHighRTP,
+					Description: "Operator has suspiciously high RTP",
+					OperatorID:  operatorID,
+					Details:     fmt.Sprintf("RTP: %.2f%%, Bets: %d, Players: %d", totals.rtp(), totals.bets, len(totals.players)),
+				})
+			}
+		}
+	}
+
+	return events
+}
+
+// spinRateDetector flags players placing more than max_spins_per_minute
+// bets within any 60 second window
+type spinRateDetector struct{}
+
+func (spinRateDetector) Rule() Rule {
+	return Rule{
+		Name:     ruleHighSpinRate,
+		Enabled:  true,
+		Severity: SeverityMedium,
+		Scope:    ScopePlayer,
+		Params:   map[string]float64{"max_spins_per_minute": 30},
+	}
+}
+
+func (spinRateDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (spinRateDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	maxSpins := int(rule.param("max_spins_per_minute"))
+	var events []SuspiciousEvent
+
+	for _, playerID := range sortedKeys(data.report.PlayerStats) {
+		pStat := data.report.PlayerStats[playerID]
+		if pStat.MaxSpinsPerMinute > maxSpins {
+			events = append(events, SuspiciousEvent{
+				Type:        EventHighSpinRate,
+				Description: "Player is spinning at an abnormally high rate (possible bot)",
+				PlayerID:    playerID,
+				Details:     fmt.Sprintf("Max %d spins/min, min interval between bets: %.2fs", pStat.MaxSpinsPerMinute, pStat.MinBetIntervalSec),
+			})
+		}
+	}
+
+	return events
+}
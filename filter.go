@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// eventFilter selects the events an analysis runs on. An event is kept when
+// it matches every predicate of the expression, except that time and amount
+// predicates decide per round, see filterStream.
+type eventFilter struct {
+	predicates []filterPredicate
+}
+
+type filterPredicate struct {
+	text  string
+	match func(data GameData) bool
+
+	// round is set for predicates deciding per round
+	round bool
+}
+
+// filterOperators are tried in order, so two-character operators win over
+// their one-character prefixes
+var filterOperators = []string{">=", "<=", "!=", "=", "<", ">"}
+
+// filterFields returns the value compared by the string predicates
+var filterFields = map[string]func(data GameData) string{
+	"player":   func(data GameData) string { return data.PlayerID },
+	"game":     func(data GameData) string { return data.GameID },
+	"operator": func(data GameData) string { return data.OperatorID },
+	"platform": func(data GameData) string { return data.PlatformID },
+	"room":     func(data GameData) string { return data.RoomID },
+	"currency": func(data GameData) string { return strings.ToUpper(data.Currency) },
+}
+
+// parseFilter parses a filter expression: predicates separated by spaces
+// or "and", each a field, an operator and a value, e.g.
+//
+//	player=p1,p2 game!=g3 time=20:00-23:00 amount>=1000
+//
+// player, game, operator, platform, room and currency take = or != and a
+// comma-separated list of values. time takes a time of day window with =
+// or !=, or an absolute time with a comparison. amount compares the bet or
+// win of the event in major units. Values containing spaces are quoted.
+func parseFilter(expression string, loc locale) (*eventFilter, error) {
+	terms, err := splitFilterTerms(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &eventFilter{}
+	for _, term := range terms {
+		if strings.EqualFold(term, "and") {
+			continue
+		}
+		predicate, err := parsePredicate(term, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %w", term, err)
+		}
+		filter.predicates = append(filter.predicates, predicate)
+	}
+	if len(filter.predicates) == 0 {
+		return nil, fmt.Errorf("filter %q has no predicates", expression)
+	}
+	return filter, nil
+}
+
+// splitFilterTerms splits an expression at spaces outside double quotes and
+// drops the quotes
+func splitFilterTerms(expression string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+
+	for _, r := range expression {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in filter %q", expression)
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+func parsePredicate(term string, loc locale) (filterPredicate, error) {
+	end := strings.IndexFunc(term, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '_'
+	})
+	if end <= 0 {
+		return filterPredicate{}, fmt.Errorf("expected field, operator and value")
+	}
+	field := strings.ToLower(term[:end])
+
+	var operator string
+	for _, candidate := range filterOperators {
+		if strings.HasPrefix(term[end:], candidate) {
+			operator = candidate
+			break
+		}
+	}
+	value := term[end+len(operator):]
+	if operator == "" || value == "" {
+		return filterPredicate{}, fmt.Errorf("expected field, operator and value")
+	}
+	if strings.ContainsAny(value[:1], "=<>!") {
+		return filterPredicate{}, fmt.Errorf("unknown operator %q", operator+value[:1])
+	}
+
+	predicate := filterPredicate{text: field + operator + value}
+	if strings.ContainsFunc(value, unicode.IsSpace) {
+		predicate.text = field + operator + strconv.Quote(value)
+	}
+	var err error
+
+	switch {
+	case filterFields[field] != nil:
+		predicate.match, err = listPredicate(filterFields[field], field, operator, value)
+	case field == "time":
+		predicate.match, err = timePredicate(operator, value, loc.Zones)
+		predicate.round = true
+	case field == "amount":
+		predicate.match, err = amountPredicate(operator, value, loc.MinorUnits)
+		predicate.round = true
+	default:
+		err = fmt.Errorf("unknown field %q", field)
+	}
+	return predicate, err
+}
+
+// listPredicate matches a field against any of a comma-separated list
+func listPredicate(fieldOf func(GameData) string, field, operator, value string) (func(GameData) bool, error) {
+	if operator != "=" && operator != "!=" {
+		return nil, fmt.Errorf("%s supports = and != only", field)
+	}
+
+	values := make(map[string]bool)
+	for _, v := range strings.Split(value, ",") {
+		if field == "currency" {
+			v = strings.ToUpper(v)
+		}
+		values[v] = true
+	}
+	negate := operator == "!="
+
+	return func(data GameData) bool {
+		return values[fieldOf(data)] != negate
+	}, nil
+}
+
+// timePredicate matches the time of day of an event in the zone of its
+// operator against a window such as 20:00-23:00, which may wrap around
+// midnight, or compares the event time with an absolute time
+func timePredicate(operator, value string, zones *timeZones) (func(GameData) bool, error) {
+	if start, end, ok := parseClockWindow(value); ok {
+		if operator != "=" && operator != "!=" {
+			return nil, fmt.Errorf("time windows support = and != only")
+		}
+		negate := operator == "!="
+
+		return func(data GameData) bool {
+			at := zones.at(data.Time, data.OperatorID)
+			clock := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute + time.Duration(at.Second())*time.Second
+			inside := clock >= start && clock < end
+			if end <= start {
+				inside = clock >= start || clock < end
+			}
+			return inside != negate
+		}, nil
+	}
+
+	t, err := parseTime(value, zones.Default)
+	if err != nil {
+		return nil, err
+	}
+	return func(data GameData) bool {
+		return compareFloat(float64(data.Time.Sub(t)), operator, 0)
+	}, nil
+}
+
+// parseClockWindow parses a time of day window such as 20:00-23:00, each
+// end as HH:MM or HH:MM:SS
+func parseClockWindow(value string) (start, end time.Duration, ok bool) {
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, okStart := parseClock(from)
+	end, okEnd := parseClock(to)
+	return start, end, okStart && okEnd
+}
+
+func parseClock(value string) (time.Duration, bool) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// amountPredicate compares the bet of a SendBet or the win of a SendWin
+// event, in major units of its currency. Other events have no amount and
+// never match.
+func amountPredicate(operator, value string, minorUnits minorUnitOverrides) (func(GameData) bool, error) {
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(limit) || math.IsInf(limit, 0) {
+		return nil, fmt.Errorf("invalid amount %q", value)
+	}
+
+	return func(data GameData) bool {
+		data = minorUnits.normalize(data)
+		var amount int64
+		switch data.Message {
+		case "SendBet":
+			amount = data.Bet
+		case "SendWin":
+			amount = data.Win
+		default:
+			return false
+		}
+		major := float64(amount) / math.Pow10(minorUnits.exponent(data.Currency))
+		return compareFloat(major, operator, limit)
+	}, nil
+}
+
+func compareFloat(a float64, operator string, b float64) bool {
+	switch operator {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// match reports whether data matches the predicates deciding per round, if
+// round is set, or the other predicates
+func (f *eventFilter) match(data GameData, round bool) bool {
+	for _, predicate := range f.predicates {
+		if predicate.round == round && !predicate.match(data) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *eventFilter) hasRoundPredicates() bool {
+	for _, predicate := range f.predicates {
+		if predicate.round {
+			return true
+		}
+	}
+	return false
+}
+
+// filterStream applies a filter to the events passed to add before handing
+// them on. A round is kept or dropped with all of its events: it is kept
+// when one of its events matches every time and amount predicate, so
+// cutting a round at a window edge or keeping only its large bet does not
+// break the wallet and round checks. Events without a round id are decided
+// alone.
+//
+// A round is decided as soon as one of its events matches, and dropped once
+// it has a bet and a win of which none matched; later events of a decided
+// round follow its decision. Only the events of rounds not decided yet are
+// held, until flush drops the rounds still undecided at the end of input.
+type filterStream struct {
+	filter *eventFilter
+	add    func(GameData) error
+
+	// open holds the events of the undecided rounds, decided whether a
+	// decided round was kept
+	open    map[string]*filterRound
+	decided map[string]bool
+
+	// players counts the bets and wins read per player, to number them
+	players map[string]int
+
+	matched, skipped int
+}
+
+// filterRound is an undecided round of a filterStream
+type filterRound struct {
+	events   []GameData
+	bet, win bool
+}
+
+func newFilterStream(filter *eventFilter, add func(GameData) error) *filterStream {
+	return &filterStream{
+		filter:  filter,
+		add:     add,
+		open:    make(map[string]*filterRound),
+		decided: make(map[string]bool),
+		players: make(map[string]int),
+	}
+}
+
+func (s *filterStream) addData(data GameData) error {
+	if data.Message == "SendBet" || data.Message == "SendWin" {
+		s.players[data.PlayerID]++
+		data.sourceSeq = s.players[data.PlayerID]
+	}
+
+	if !s.filter.match(data, false) {
+		s.skipped++
+		return nil
+	}
+	if !s.filter.hasRoundPredicates() {
+		return s.keep(data)
+	}
+	if data.RoundID == "" {
+		if !s.filter.match(data, true) {
+			s.skipped++
+			return nil
+		}
+		return s.keep(data)
+	}
+
+	key := roundKey(data)
+	if kept, ok := s.decided[key]; ok {
+		if !kept {
+			s.skipped++
+			return nil
+		}
+		return s.keep(data)
+	}
+
+	round := s.open[key]
+	if round == nil {
+		round = &filterRound{}
+		s.open[key] = round
+	}
+	round.events = append(round.events, data)
+	round.bet = round.bet || data.Message == "SendBet"
+	round.win = round.win || data.Message == "SendWin"
+
+	switch {
+	case s.filter.match(data, true):
+		s.decided[key] = true
+		delete(s.open, key)
+		for _, event := range round.events {
+			if err := s.keep(event); err != nil {
+				return err
+			}
+		}
+	case round.bet && round.win:
+		s.decided[key] = false
+		delete(s.open, key)
+		s.skipped += len(round.events)
+	}
+	return nil
+}
+
+func (s *filterStream) keep(data GameData) error {
+	s.matched++
+	return s.add(data)
+}
+
+// flush drops the rounds left undecided, none of their events matched
+func (s *filterStream) flush() {
+	for key, round := range s.open {
+		s.skipped += len(round.events)
+		s.decided[key] = false
+	}
+	clear(s.open)
+}
+
+// String returns the expression in normalized form
+func (f *eventFilter) String() string {
+	texts := make([]string, len(f.predicates))
+	for i, predicate := range f.predicates {
+		texts[i] = predicate.text
+	}
+	return strings.Join(texts, " and ")
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLocale() locale {
+	return locale{MinorUnits: minorUnitOverrides{}, Zones: &timeZones{Default: time.UTC}}
+}
+
+// filterEvent returns a bet or win of p1 in round, amount in minor units
+// and at seconds after 2025-12-26 20:00 UTC
+func filterEvent(message, round string, amount int64, seconds int) GameData {
+	data := GameData{
+		Message:    message,
+		PlayerID:   "p1",
+		OperatorID: "op1",
+		Currency:   "NGN",
+		RoundID:    round,
+		Time:       time.Date(2025, 12, 26, 20, 0, seconds, 0, time.UTC),
+	}
+	if message == "SendBet" {
+		data.Bet = amount
+	} else {
+		data.Win = amount
+	}
+	return data
+}
+
+func TestFilterStreamDecidesPerRound(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		filter string
+		events []GameData
+		kept   string
+	}{
+		{
+			name:   "kept by its large win",
+			filter: "amount>=10",
+			events: []GameData{filterEvent("SendBet", "r1", 100, 0), filterEvent("SendWin", "r1", 5000, 1)},
+			kept:   "r1 r1",
+		},
+		{
+			name:   "dropped once settled without a match",
+			filter: "amount>=10",
+			events: []GameData{filterEvent("SendBet", "r1", 100, 0), filterEvent("SendWin", "r1", 200, 1), filterEvent("SendWin", "r1", 5000, 2)},
+			kept:   "",
+		},
+		{
+			name:   "win before its bet",
+			filter: "amount>=10",
+			events: []GameData{filterEvent("SendWin", "r1", 200, 1), filterEvent("SendBet", "r1", 5000, 0)},
+			kept:   "r1 r1",
+		},
+		{
+			name:   "cut at the window start",
+			filter: `time>="2025-12-26 20:00:01"`,
+			events: []GameData{filterEvent("SendBet", "r1", 100, 0), filterEvent("SendWin", "r1", 200, 1), filterEvent("SendBet", "r0", 100, -5), filterEvent("SendWin", "r0", 100, -4)},
+			kept:   "r1 r1",
+		},
+		{
+			name:   "open round left undecided",
+			filter: "amount>=10",
+			events: []GameData{filterEvent("SendBet", "r1", 100, 0), filterEvent("SendBet", "r2", 1000, 1)},
+			kept:   "r2",
+		},
+		{
+			name:   "event without a round",
+			filter: "amount>=10",
+			events: []GameData{filterEvent("SendBet", "", 1000, 0), filterEvent("SendBet", "", 100, 1)},
+			kept:   "-",
+		},
+		{
+			name:   "other predicates per event",
+			filter: "amount>=10 operator!=op1",
+			events: []GameData{filterEvent("SendBet", "r1", 1000, 0), filterEvent("SendWin", "r1", 1000, 1)},
+			kept:   "",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := parseFilter(test.filter, testLocale())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var kept []string
+			stream := newFilterStream(filter, func(data GameData) error {
+				if data.RoundID == "" {
+					data.RoundID = "-"
+				}
+				kept = append(kept, data.RoundID)
+				return nil
+			})
+			for _, data := range test.events {
+				if err := stream.addData(data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stream.flush()
+
+			if got := strings.Join(kept, " "); got != test.kept {
+				t.Errorf("got rounds %q kept, want %q", got, test.kept)
+			}
+			if stream.matched+stream.skipped != len(test.events) {
+				t.Errorf("got %d matched and %d skipped of %d events", stream.matched, stream.skipped, len(test.events))
+			}
+			if len(stream.open) != 0 {
+				t.Errorf("got %d rounds still held after flush", len(stream.open))
+			}
+		})
+	}
+}
+
+// A filtered round leaves a gap in the balance history: the bet after it is
+// not checked against the last kept balance, but kept rounds read next to
+// each other still are
+func TestFilteredWalletChecksAdjacentEvents(t *testing.T) {
+	filter, err := parseFilter("amount>=10", testLocale())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := newWalletLedger()
+	ledger.filtered = true
+	stream := newFilterStream(filter, func(data GameData) error {
+		ledger.add(data)
+		return nil
+	})
+
+	for i, event := range []struct {
+		message, round  string
+		amount, balance int64
+	}{
+		{"SendBet", "r1", 1000, 9000},
+		{"SendWin", "r1", 0, 9000},
+		// Not debited
+		{"SendBet", "r2", 1000, 9000},
+		{"SendWin", "r2", 0, 9000},
+		// Filtered out
+		{"SendBet", "r3", 5, 8995},
+		{"SendWin", "r3", 0, 8995},
+		{"SendBet", "r4", 1000, 7995},
+	} {
+		data := filterEvent(event.message, event.round, event.amount, i)
+		data.Balance = event.balance
+		if err := stream.addData(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.flush()
+
+	summary, events, _ := ledger.reconcile(minorUnitOverrides{}.currency("NGN"), &timeZones{Default: time.UTC})
+	if summary.Breaks != 1 || summary.MissingDebits != 1 {
+		t.Errorf("got %d breaks and %d missing debits, want the r2 bet only: %+v", summary.Breaks, summary.MissingDebits, events)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	for _, test := range []struct {
+		expression string
+		want       string
+		err        bool
+	}{
+		{expression: "player=p1,p2 game!=g3", want: "player=p1,p2 and game!=g3"},
+		{expression: "currency=ngn and amount>=10.5", want: "currency=ngn and amount>=10.5"},
+		{expression: `time=20:00-23:00 time<"2025-12-27 00:00"`, want: `time=20:00-23:00 and time<"2025-12-27 00:00"`},
+		{expression: `room="lobby 1"`, want: `room="lobby 1"`},
+		{expression: "and", err: true},
+		{expression: "and and", err: true},
+		{expression: "player==p1", err: true},
+		{expression: "amount=>10", err: true},
+		{expression: "amount<>10", err: true},
+		{expression: "player=", err: true},
+		{expression: "player", err: true},
+		{expression: "=p1", err: true},
+		{expression: "player>p1", err: true},
+		{expression: "colour=red", err: true},
+		{expression: "amount>=NaN", err: true},
+		{expression: "amount<Inf", err: true},
+		{expression: "amount>-Infinity", err: true},
+		{expression: "amount>=ten", err: true},
+		{expression: "time>=yesterday", err: true},
+		{expression: "time<20:00-23:00", err: true},
+		{expression: `room="lobby`, err: true},
+	} {
+		filter, err := parseFilter(test.expression, testLocale())
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: got filter %s, want an error", test.expression, filter)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if got := filter.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.expression, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// EventHitRateOutlier flags a player whose share of winning rounds deviates
+// from the other players of the same games
+const EventHitRateOutlier EventType = "Hit Rate Outlier"
+
+// multiplierBucketEdges are the lower bounds of the win multiplier buckets
+// after the first, which starts at 0
+var multiplierBucketEdges = []float64{1, 5, 20, 100}
+
+// HitStats describes how often rounds win and by how much. Only settled
+// rounds with a bet are counted.
+type HitStats struct {
+	Rounds int `json:"rounds"`
+	Hits   int `json:"hits"`
+
+	// HitRate is the percentage of rounds with a win
+	HitRate float64 `json:"hit_rate_percentage"`
+
+	MaxMultiplier        float64 `json:"max_multiplier"`
+	MaxMultiplierRoundID string  `json:"max_multiplier_round_id,omitempty"`
+
+	// Multipliers counts the winning rounds per multiplier bucket
+	Multipliers []MultiplierBucket `json:"multipliers"`
+}
+
+// MultiplierBucket counts the winning rounds with a multiplier from MinX up
+// to the MinX of the next bucket
+type MultiplierBucket struct {
+	Label string  `json:"label"`
+	MinX  float64 `json:"min_x"`
+	Count int     `json:"count"`
+}
+
+// HitRateTest compares a player's hit rate with the hit rate of the other
+// players of the games played. PValue is the two-sided probability of a
+// deviation at least this large by chance.
+type HitRateTest struct {
+	Rounds          int     `json:"rounds"`
+	HitRate         float64 `json:"hit_rate_percentage"`
+	ExpectedHitRate float64 `json:"expected_hit_rate_percentage"`
+	ZScore          float64 `json:"z_score"`
+	PValue          float64 `json:"p_value"`
+}
+
+func newHitStats() *HitStats {
+	buckets := make([]MultiplierBucket, len(multiplierBucketEdges)+1)
+	lower := 0.0
+	for i := range buckets {
+		buckets[i].MinX = lower
+		if i < len(multiplierBucketEdges) {
+			buckets[i].Label = fmt.Sprintf("%g-%gx", lower, multiplierBucketEdges[i])
+			lower = multiplierBucketEdges[i]
+		} else {
+			buckets[i].Label = fmt.Sprintf("%gx+", lower)
+		}
+	}
+	return &HitStats{Multipliers: buckets}
+}
+
+func (h *HitStats) add(round *Round) {
+	h.Rounds++
+	if round.TotalWin > 0 {
+		h.Hits++
+		i := sort.SearchFloat64s(multiplierBucketEdges, round.Multiplier)
+		// SearchFloat64s finds the first edge >= multiplier, an exact edge
+		// belongs to the bucket it starts
+		if i < len(multiplierBucketEdges) && multiplierBucketEdges[i] == round.Multiplier {
+			i++
+		}
+		h.Multipliers[i].Count++
+	}
+	if round.Multiplier > h.MaxMultiplier {
+		h.MaxMultiplier = round.Multiplier
+		h.MaxMultiplierRoundID = round.RoundID
+	}
+	h.HitRate = float64(h.Hits) / float64(h.Rounds) * 100
+}
+
+// analyzeHits computes the hit statistics per game and per player and tests
+// each player's hit rate against the rest of the population of every game
+// they played. A game's hit rate comes from its profile when set.
+func analyzeHits(rounds []*Round, profiles map[string]GameProfile) (games, players map[string]*HitStats, tests map[string]*HitRateTest) {
+	games = make(map[string]*HitStats)
+	players = make(map[string]*HitStats)
+	playerGames := make(map[string]map[string]*HitStats)
+
+	for _, round := range rounds {
+		if round.TotalBet <= 0 || round.Open() {
+			continue
+		}
+
+		if games[round.GameID] == nil {
+			games[round.GameID] = newHitStats()
+		}
+		games[round.GameID].add(round)
+
+		if players[round.PlayerID] == nil {
+			players[round.PlayerID] = newHitStats()
+			playerGames[round.PlayerID] = make(map[string]*HitStats)
+		}
+		players[round.PlayerID].add(round)
+
+		if playerGames[round.PlayerID][round.GameID] == nil {
+			playerGames[round.PlayerID][round.GameID] = newHitStats()
+		}
+		playerGames[round.PlayerID][round.GameID].add(round)
+	}
+
+	tests = make(map[string]*HitRateTest)
+	for playerID, perGame := range playerGames {
+		var rounds, hits int
+		var expected, variance float64
+
+		for gameID, stats := range perGame {
+			p, ok := expectedHitRate(profiles[gameID], games[gameID], stats)
+			if !ok {
+				continue
+			}
+			rounds += stats.Rounds
+			hits += stats.Hits
+			expected += p * float64(stats.Rounds)
+			variance += p * (1 - p) * float64(stats.Rounds)
+		}
+
+		if rounds == 0 || variance == 0 {
+			continue
+		}
+
+		z := (float64(hits) - expected) / math.Sqrt(variance)
+		tests[playerID] = &HitRateTest{
+			Rounds:          rounds,
+			HitRate:         float64(hits) / float64(rounds) * 100,
+			ExpectedHitRate: expected / float64(rounds) * 100,
+			ZScore:          z,
+			PValue:          math.Erfc(math.Abs(z) / math.Sqrt2),
+		}
+	}
+
+	return games, players, tests
+}
+
+// expectedHitRate returns the probability of a winning round in a game from
+// its profile, falling back to the rounds of the other players
+func expectedHitRate(profile GameProfile, game, player *HitStats) (float64, bool) {
+	if profile.HitRate > 0 {
+		return profile.HitRate / 100, true
+	}
+
+	others := game.Rounds - player.Rounds
+	if others < minPopulationRounds {
+		return 0, false
+	}
+	return float64(game.Hits-player.Hits) / float64(others), true
+}
+
+// formatMultipliers lists the non-empty multiplier buckets
+func formatMultipliers(buckets []MultiplierBucket) string {
+	var parts []string
+	for _, bucket := range buckets {
+		if bucket.Count > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", bucket.Label, bucket.Count))
+		}
+	}
+	if len(parts) == 0 {
+		return "no wins"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printHitStats(w io.Writer, hits *HitStats) {
+	fmt.Fprintf(w, "├─ Hit Frequency: %.2f%% of %d rounds, Max Multiplier: %.2fx", hits.HitRate, hits.Rounds, hits.MaxMultiplier)
+	if hits.MaxMultiplierRoundID != "" {
+		fmt.Fprintf(w, " (round %s)", hits.MaxMultiplierRoundID)
+	}
+	fmt.Fprintf(w, "\n├─ Win Multipliers: %s\n", formatMultipliers(hits.Multipliers))
+}
+
+// hitRateDetector flags players whose hit rate deviates from expectation
+// with a two-sided p-value below 1 - confidence over at least min_rounds
+// rounds
+type hitRateDetector struct{}
+
+func (hitRateDetector) Rule() Rule {
+	return Rule{
+		Name:     "hit_rate_outlier",
+		Enabled:  true,
+		Severity: SeverityMedium,
+		Scope:    ScopePlayer,
+		Params:   map[string]float64{"confidence": 0.999, "min_rounds": 50},
+	}
+}
+
+func (hitRateDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (hitRateDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	alpha := 1 - rule.param("confidence")
+	minRounds := int(rule.param("min_rounds"))
+	var events []SuspiciousEvent
+
+	for _, playerID := range sortedKeys(data.report.PlayerStats) {
+		test := data.report.PlayerStats[playerID].HitTest
+		if test == nil || test.Rounds < minRounds || test.PValue >= alpha {
+			continue
+		}
+
+		direction := "more"
+		if test.ZScore < 0 {
+			direction = "less"
+		}
+		events = append(events, SuspiciousEvent{
+			Type:        EventHitRateOutlier,
+			Description: "Player wins " + direction + " often than other players of the same games",
+			PlayerID:    playerID,
+			Details: fmt.Sprintf("Hit rate %.2f%% vs expected %.2f%% over %d rounds, z = %.2f, p = %.2g",
+				test.HitRate, test.ExpectedHitRate, test.Rounds, test.ZScore, test.PValue),
+		})
+	}
+
+	return events
+}
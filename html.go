@@ -0,0 +1,415 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed templates/report.html
+var htmlReportTemplate string
+
+const formatHTML = "html"
+
+// htmlPage is the data rendered by templates/report.html
+type htmlPage struct {
+	Title       string
+	Period      string
+	GeneratedAt string
+	Metadata    *ReportMetadata
+	Incomplete  bool
+	Coverage    *CoverageReport
+	Sections    []htmlView
+	Note        string
+}
+
+// htmlView is a single-currency section of the page. Charts are laid out
+// here as plain SVG geometry, so the page needs no external scripts.
+type htmlView struct {
+	Heading       string
+	Report        Report
+	Currency      currencyFormat
+	Timeline      svgChart
+	Heatmap       []htmlHeatmapRow
+	PlayerRTP     svgChart
+	Balances      []svgTimeline
+	Players       []PlayerStat
+	Games         []GameStat
+	Segments      []htmlSegments
+	Suspicious    []SuspiciousEvent
+	Daily         []DailyReport
+	DayOverDay    []DayComparison
+	SpinFlagged   map[string]bool
+	TimingFlagged map[string]bool
+	HitFlagged    map[string]bool
+	TopPlayerCap  int
+
+	// BetPatterns lists the bet patterns flagged per player
+	BetPatterns map[string]string
+}
+
+type svgChart struct {
+	Width     int
+	Height    int
+	Bars      []svgBar
+	Reference *svgLine
+}
+
+type svgBar struct {
+	X, Y, W, H float64
+	Label      string
+	LabelX     float64
+	Title      string
+	Class      string
+}
+
+type svgLine struct {
+	X1, Y1, X2, Y2 float64
+	Label          string
+}
+
+// htmlSegments is the operator or platform table
+type htmlSegments struct {
+	Heading string
+	Column  string
+	Stats   []SegmentStat
+}
+
+// htmlHeatmapRow is one weekday of the activity heatmap
+type htmlHeatmapRow struct {
+	Day   string
+	Cells []htmlHeatmapCell
+}
+
+// htmlHeatmapCell is shaded with Opacity, the share of the bets of the
+// busiest hour
+type htmlHeatmapCell struct {
+	Bets    int
+	Opacity float64
+	Title   string
+}
+
+type svgTimeline struct {
+	PlayerID string
+	Width    int
+	Height   int
+	Points   string
+	Min      int64
+	Max      int64
+	Start    string
+	End      string
+}
+
+const (
+	chartWidth      = 720
+	chartHeight     = 220
+	chartPadding    = 30
+	chartLabels     = 24
+	htmlTopPlayers  = 20
+	htmlTimelines   = 10
+	timelineWidth   = 340
+	timelineHeight  = 120
+	timelinePadding = 6
+)
+
+func writeHTMLReport(w io.Writer, report Report) error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"money": formatMoney,
+		"pct": func(value float64) string {
+			return fmt.Sprintf("%.2f%%", value)
+		},
+		"float": func(value float64) string {
+			return fmt.Sprintf("%.2f", value)
+		},
+		"inc": func(i int) int {
+			return i + 1
+		},
+	}).Parse(htmlReportTemplate)
+	if err != nil {
+		return fmt.Errorf("parsing html template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, newHTMLPage(report)); err != nil {
+		return fmt.Errorf("rendering html report: %w", err)
+	}
+	return nil
+}
+
+func newHTMLPage(report Report) htmlPage {
+	page := htmlPage{
+		Title:       "Gaming Logs Analysis Report",
+		Period:      report.Summary.TimeSpan,
+		GeneratedAt: time.Now().In(report.locale.Zones.Default).Format(timeLayout),
+		Metadata:    report.Metadata,
+		Incomplete:  report.Summary.Incomplete,
+		Coverage:    report.Coverage,
+	}
+	if report.Metadata != nil {
+		page.GeneratedAt = report.Metadata.GeneratedAt.In(report.locale.Zones.Default).Format(timeLayout)
+	}
+
+	if len(report.Currencies) == 0 {
+		page.Sections = append(page.Sections, newHTMLView(report, ""))
+		return page
+	}
+
+	for _, currencyReport := range currencyReports(report) {
+		if page.Period == "" {
+			page.Period = currencyReport.Summary.TimeSpan
+		}
+		page.Sections = append(page.Sections, newHTMLView(currencyReport, "Currency: "+currencyReport.Currency))
+	}
+
+	if report.Currency == "" {
+		page.Note = fmt.Sprintf("No reporting currency configured - amounts in %d currencies are not combined. Use -rates to convert them.", len(report.Currencies))
+	} else {
+		page.Sections = append(page.Sections, newHTMLView(report, "All currencies (converted to "+report.Currency+")"))
+	}
+
+	return page
+}
+
+func newHTMLView(report Report, heading string) htmlView {
+	view := htmlView{
+		Heading:       heading,
+		Report:        report,
+		Currency:      report.money(),
+		Suspicious:    report.SuspiciousEvents,
+		Daily:         report.Daily,
+		DayOverDay:    report.DayOverDay,
+		SpinFlagged:   make(map[string]bool),
+		TimingFlagged: make(map[string]bool),
+		HitFlagged:    make(map[string]bool),
+		TopPlayerCap:  htmlTopPlayers,
+		BetPatterns:   make(map[string]string),
+	}
+
+	for _, stat := range report.PlayerStats {
+		view.Players = append(view.Players, stat)
+	}
+	sort.Slice(view.Players, func(i, j int) bool {
+		return view.Players[i].TotalBetAmount > view.Players[j].TotalBetAmount
+	})
+
+	for _, stat := range report.GameStats {
+		view.Games = append(view.Games, stat)
+	}
+	sort.Slice(view.Games, func(i, j int) bool {
+		return view.Games[i].GameID < view.Games[j].GameID
+	})
+
+	for _, event := range report.SuspiciousEvents {
+		switch event.Type {
+		case EventHighSpinRate:
+			view.SpinFlagged[event.PlayerID] = true
+		case EventBotTiming:
+			view.TimingFlagged[event.PlayerID] = true
+		case EventHitRateOutlier:
+			view.HitFlagged[event.PlayerID] = true
+		case EventMartingale, EventStepUpBigWin, EventRepeatingStake:
+			if view.BetPatterns[event.PlayerID] != "" {
+				view.BetPatterns[event.PlayerID] += ", "
+			}
+			view.BetPatterns[event.PlayerID] += event.Rule
+		}
+	}
+
+	view.Segments = []htmlSegments{
+		{Heading: "🏢 Operators", Column: "Operator", Stats: sortedSegments(report.OperatorStats)},
+		{Heading: "🖥️ Platforms", Column: "Platform", Stats: sortedSegments(report.PlatformStats)},
+	}
+
+	view.Timeline = timelineChart(report.TimeStats, report.money())
+	if report.Heatmap != nil {
+		view.Heatmap = heatmapTable(report.Heatmap, report.money())
+	}
+	view.PlayerRTP = playerRTPChart(view.Players)
+
+	for i, player := range view.Players {
+		if i == htmlTimelines {
+			break
+		}
+		if timeline, ok := balanceTimeline(player, report.locale.Zones); ok {
+			view.Balances = append(view.Balances, timeline)
+		}
+	}
+
+	return view
+}
+
+// timelineChart draws the number of bets of each timeline bucket. At most
+// chartLabels buckets are labelled, so labels do not overlap.
+func timelineChart(stats []TimeStat, currency currencyFormat) svgChart {
+	chart := svgChart{Width: chartWidth, Height: chartHeight}
+	if len(stats) == 0 {
+		return chart
+	}
+
+	maxBets := 0
+	for _, stat := range stats {
+		maxBets = max(maxBets, stat.TotalBets)
+	}
+
+	plotHeight := float64(chartHeight - chartPadding)
+	slot := float64(chartWidth) / float64(len(stats))
+	gap := math.Min(4, slot/5)
+	labelEvery := (len(stats) + chartLabels - 1) / chartLabels
+	for i, stat := range stats {
+		h := 0.0
+		if maxBets > 0 {
+			h = float64(stat.TotalBets) / float64(maxBets) * (plotHeight - 10)
+		}
+		class := "bar"
+		if stat.RTP > 100 {
+			class = "bar bar-high"
+		}
+		bar := svgBar{
+			X:      float64(i)*slot + gap/2,
+			Y:      plotHeight - h,
+			W:      slot - gap,
+			H:      h,
+			LabelX: float64(i)*slot + slot/2,
+			Title: fmt.Sprintf("%s - %d bets, %d wins, %d players, RTP %.2f%%, volume %s",
+				stat.label, stat.TotalBets, stat.TotalWins, stat.ActivePlayers, stat.RTP, formatMoney(stat.TotalBetAmount, currency)),
+			Class: class,
+		}
+		if i%labelEvery == 0 {
+			bar.Label = shortTimeLabel(stat)
+		}
+		chart.Bars = append(chart.Bars, bar)
+	}
+
+	return chart
+}
+
+// shortTimeLabel labels a bucket with its time of day, or with its date
+// when it starts at midnight
+func shortTimeLabel(stat TimeStat) string {
+	start, err := time.Parse(timeLayout, stat.Start)
+	if err != nil {
+		return ""
+	}
+	if start.Hour() == 0 && start.Minute() == 0 {
+		return start.Format("01-02")
+	}
+	return start.Format("15:04")
+}
+
+// heatmapTable lays out the heatmap Monday first
+func heatmapTable(heatmap *ActivityHeatmap, currency currencyFormat) []htmlHeatmapRow {
+	maxBets := 0
+	for _, hours := range heatmap.Days {
+		for _, activity := range hours {
+			maxBets = max(maxBets, activity.TotalBets)
+		}
+	}
+
+	rows := make([]htmlHeatmapRow, 0, len(weekdaysFromMonday))
+	for _, day := range weekdaysFromMonday {
+		row := htmlHeatmapRow{Day: day.String()[:3]}
+		for hour, activity := range heatmap.Days[day] {
+			cell := htmlHeatmapCell{
+				Bets: activity.TotalBets,
+				Title: fmt.Sprintf("%s %02d:00 - %d bets, %d players, RTP %.2f%%, volume %s",
+					day, hour, activity.TotalBets, activity.ActivePlayers, activity.RTP, formatMoney(activity.TotalBetAmount, currency)),
+			}
+			if maxBets > 0 {
+				cell.Opacity = float64(activity.TotalBets) / float64(maxBets)
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// playerRTPChart draws the RTP of the players with the largest volume with
+// a reference line at 100%
+func playerRTPChart(players []PlayerStat) svgChart {
+	players = players[:min(htmlTopPlayers, len(players))]
+	chart := svgChart{Width: chartWidth, Height: chartHeight}
+	if len(players) == 0 {
+		return chart
+	}
+
+	maxRTP := 100.0
+	for _, p := range players {
+		maxRTP = math.Max(maxRTP, p.RTP)
+	}
+	maxRTP *= 1.1
+
+	plotHeight := float64(chartHeight - chartPadding)
+	slot := float64(chartWidth) / float64(len(players))
+	for i, p := range players {
+		h := p.RTP / maxRTP * plotHeight
+		class := "bar"
+		if p.RTP > 100 {
+			class = "bar bar-high"
+		}
+		x := float64(i) * slot
+		chart.Bars = append(chart.Bars, svgBar{
+			X:      x + 2,
+			Y:      plotHeight - h,
+			W:      slot - 4,
+			H:      h,
+			Label:  shortLabel(p.PlayerID),
+			LabelX: x + slot/2,
+			Title:  fmt.Sprintf("%s - RTP %.2f%%, %d bets", p.PlayerID, p.RTP, p.TotalBets),
+			Class:  class,
+		})
+	}
+
+	y := plotHeight - 100/maxRTP*plotHeight
+	chart.Reference = &svgLine{X1: 0, Y1: y, X2: chartWidth, Y2: y, Label: "100%"}
+
+	return chart
+}
+
+func balanceTimeline(player PlayerStat, zones *timeZones) (svgTimeline, bool) {
+	points := player.BalanceTimeline
+	if len(points) < 2 {
+		return svgTimeline{}, false
+	}
+
+	timeline := svgTimeline{
+		PlayerID: player.PlayerID,
+		Width:    timelineWidth,
+		Height:   timelineHeight,
+		Min:      points[0].Balance,
+		Max:      points[0].Balance,
+		Start:    zones.at(unixTime(points[0].Timestamp), "").Format(timeLayout),
+		End:      zones.at(unixTime(points[len(points)-1].Timestamp), "").Format(timeLayout),
+	}
+	for _, p := range points {
+		timeline.Min = min(timeline.Min, p.Balance)
+		timeline.Max = max(timeline.Max, p.Balance)
+	}
+
+	start, end := points[0].Timestamp, points[len(points)-1].Timestamp
+	span := math.Max(end-start, 1)
+	valueRange := math.Max(float64(timeline.Max-timeline.Min), 1)
+	plotWidth := float64(timelineWidth - 2*timelinePadding)
+	plotHeight := float64(timelineHeight - 2*timelinePadding)
+
+	coords := make([]string, len(points))
+	for i, p := range points {
+		x := timelinePadding + (p.Timestamp-start)/span*plotWidth
+		y := timelinePadding + plotHeight - float64(p.Balance-timeline.Min)/valueRange*plotHeight
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	timeline.Points = strings.Join(coords, " ")
+
+	return timeline, true
+}
+
+func shortLabel(id string) string {
+	if len(id) <= 6 {
+		return id
+	}
+	return "…" + id[len(id)-5:]
+}
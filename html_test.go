@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLSuspiciousEventShowsPlayerBeforeOperator(t *testing.T) {
+	report := Report{
+		Currency:    "NGN",
+		PlayerStats: make(map[string]PlayerStat),
+		GameStats:   make(map[string]GameStat),
+		SuspiciousEvents: []SuspiciousEvent{
+			{Type: EventOrphanWin, PlayerID: "p1", OperatorID: "op1", RoundID: "r1"},
+			{Type: EventHighRTP, OperatorID: "op2"},
+		},
+		locale: locale{MinorUnits: minorUnitOverrides{}, Zones: &timeZones{Default: time.UTC}},
+	}
+
+	var html strings.Builder
+	if err := writeHTMLReport(&html, report); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"<td>p1</td>", "<td>operator op2</td>"} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("got no %s cell in the suspicious activity table", want)
+		}
+	}
+	if strings.Contains(html.String(), "p1op1") {
+		t.Error("got the player and operator ids glued together")
+	}
+}
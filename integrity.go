@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Round integrity findings
+const (
+	EventOrphanWin     EventType = "Orphan Win"
+	EventMultipleBets  EventType = "Multiple Bets"
+	EventWinBeforeBet  EventType = "Win Before Bet"
+	EventRoundMismatch EventType = "Round Player/Game Change"
+	EventOpenRound     EventType = "Open Round"
+)
+
+// roundDetector applies an integrity check to every reconstructed round.
+// The round, player and game of the findings are filled in.
+type roundDetector struct {
+	rule  Rule
+	check func(round *Round, rule Rule, data *detectionData) []roundFinding
+}
+
+// roundFinding is an integrity problem of a round between start and end
+type roundFinding struct {
+	eventType   EventType
+	description string
+	start, end  time.Time
+	amount      int64
+	details     string
+}
+
+func (d roundDetector) Rule() Rule {
+	rule := d.rule
+	rule.Params = make(map[string]float64, len(d.rule.Params))
+	for name, value := range d.rule.Params {
+		rule.Params[name] = value
+	}
+	return rule
+}
+
+func (d roundDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (d roundDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	var events []SuspiciousEvent
+
+	for _, round := range data.rounds {
+		for _, finding := range d.check(round, rule, data) {
+			event := SuspiciousEvent{
+				Type:        finding.eventType,
+				Description: finding.description,
+				PlayerID:    round.PlayerID,
+				GameID:      round.GameID,
+				OperatorID:  round.OperatorID,
+				PlatformID:  round.PlatformID,
+				RoundID:     round.RoundID,
+				Timestamp:   data.report.locale.Zones.timestamp(finding.start, round.OperatorID),
+				Amount:      finding.amount,
+				Details:     finding.details,
+			}
+			if !finding.end.Equal(finding.start) {
+				event.EndTimestamp = data.report.locale.Zones.timestamp(finding.end, round.OperatorID)
+			}
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// orphanWinDetector flags wins with no bet earlier in their round
+var orphanWinDetector = roundDetector{
+	rule: Rule{Name: "orphan_win", Enabled: true, Severity: SeverityHigh, Scope: ScopePlayer},
+	check: func(round *Round, rule Rule, data *detectionData) []roundFinding {
+		var orphans []*RoundEvent
+		for i := range round.Pairs {
+			if round.Pairs[i].Bet == nil {
+				orphans = append(orphans, &round.Pairs[i].Win)
+			}
+		}
+		if len(orphans) == 0 {
+			return nil
+		}
+
+		var amount int64
+		for _, win := range orphans {
+			amount += win.Amount
+		}
+		details := fmt.Sprintf("%d win(s) totalling %s, first win %s at step %d",
+			len(orphans), formatMoney(amount, data.report.money()), orphans[0].ID, orphans[0].Step)
+		if len(round.Bets) > 0 {
+			details += fmt.Sprintf("; the round's first bet is at step %d", round.Bets[0].Step)
+		}
+
+		return []roundFinding{{
+			eventType:   EventOrphanWin,
+			description: "Win has no matching bet in its round",
+			start:       orphans[0].Time,
+			end:         orphans[len(orphans)-1].Time,
+			amount:      amount,
+			details:     details,
+		}}
+	},
+}
+
+// multipleBetsDetector flags rounds with more than one bet
+var multipleBetsDetector = roundDetector{
+	rule: Rule{Name: "multiple_bets", Enabled: true, Severity: SeverityMedium, Scope: ScopePlayer},
+	check: func(round *Round, rule Rule, data *detectionData) []roundFinding {
+		if len(round.Bets) < 2 {
+			return nil
+		}
+
+		return []roundFinding{{
+			eventType:   EventMultipleBets,
+			description: "Round contains more than one bet",
+			start:       round.Bets[0].Time,
+			end:         round.Bets[len(round.Bets)-1].Time,
+			amount:      round.TotalBet,
+			details:     fmt.Sprintf("%d bets totalling %s", len(round.Bets), formatMoney(round.TotalBet, data.report.money())),
+		}}
+	},
+}
+
+// winBeforeBetDetector flags wins timestamped more than min_gap_sec before
+// the bet they settle
+var winBeforeBetDetector = roundDetector{
+	rule: Rule{
+		Name: "win_before_bet", Enabled: true, Severity: SeverityHigh, Scope: ScopePlayer,
+		Params: map[string]float64{"min_gap_sec": 0},
+	},
+	check: func(round *Round, rule Rule, data *detectionData) []roundFinding {
+		var findings []roundFinding
+		for _, pair := range round.Pairs {
+			if pair.Bet == nil {
+				continue
+			}
+			gap := pair.Bet.Time.Sub(pair.Win.Time).Seconds()
+			if gap <= 0 || gap <= rule.param("min_gap_sec") {
+				continue
+			}
+
+			findings = append(findings, roundFinding{
+				eventType:   EventWinBeforeBet,
+				description: "Win is timestamped before the bet it settles",
+				start:       pair.Win.Time,
+				end:         pair.Bet.Time,
+				amount:      pair.Win.Amount,
+				details: fmt.Sprintf("Bet %s at step %d, win %s at step %d, %.3fs earlier",
+					pair.Bet.ID, pair.Bet.Step, pair.Win.ID, pair.Win.Step, gap),
+			})
+		}
+		return findings
+	},
+}
+
+// roundChangeDetector flags rounds whose events belong to more than one
+// player or game
+var roundChangeDetector = roundDetector{
+	rule: Rule{Name: "round_player_game_change", Enabled: true, Severity: SeverityHigh, Scope: ScopePlayer},
+	check: func(round *Round, rule Rule, data *detectionData) []roundFinding {
+		players, games := roundParticipants(round)
+		if len(players) < 2 && len(games) < 2 {
+			return nil
+		}
+
+		return []roundFinding{{
+			eventType:   EventRoundMismatch,
+			description: "Player or game changes in the middle of the round",
+			start:       round.StartTime,
+			end:         round.EndTime,
+			details:     fmt.Sprintf("Players: %s; games: %s", strings.Join(players, ", "), strings.Join(games, ", ")),
+		}}
+	},
+}
+
+// openRoundDetector flags rounds whose last bet is still unsettled at least
+// min_open_sec before the end of the data
+var openRoundDetector = roundDetector{
+	rule: Rule{
+		Name: "open_round", Enabled: true, Severity: SeverityLow, Scope: ScopePlayer,
+		Params: map[string]float64{"min_open_sec": 0},
+	},
+	check: func(round *Round, rule Rule, data *detectionData) []roundFinding {
+		if !round.Open() {
+			return nil
+		}
+		last := round.Bets[len(round.Bets)-1]
+		open := data.windowEnd.Sub(last.Time).Seconds()
+		if open < rule.param("min_open_sec") {
+			return nil
+		}
+
+		return []roundFinding{{
+			eventType:   EventOpenRound,
+			description: "Round has a bet but no win by the end of the data",
+			start:       round.StartTime,
+			end:         last.Time,
+			amount:      round.TotalBet,
+			details: fmt.Sprintf("Bet of %s unsettled for %.0fs until the end of the window",
+				formatMoney(round.TotalBet, data.report.money()), open),
+		}}
+	},
+}
+
+// roundParticipants returns the distinct players and games of a round in
+// order of appearance
+func roundParticipants(round *Round) (players, games []string) {
+	seenPlayers := make(map[string]bool)
+	seenGames := make(map[string]bool)
+
+	events := append([]RoundEvent(nil), round.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+
+	for _, event := range events {
+		if !seenPlayers[event.PlayerID] {
+			seenPlayers[event.PlayerID] = true
+			players = append(players, event.PlayerID)
+		}
+		if !seenGames[event.GameID] {
+			seenGames[event.GameID] = true
+			games = append(games, event.GameID)
+		}
+	}
+	return players, games
+}
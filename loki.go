@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lokiClient pages through the Loki query_range API. Windows that return
+// the maximum number of lines are split in half until every window fits
+// under the limit or minWindow is reached.
+type lokiClient struct {
+	baseURL    string
+	orgID      string
+	limit      int
+	minWindow  time.Duration
+	httpClient *http.Client
+}
+
+type lokiQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func newLokiClient(baseURL string) *lokiClient {
+	return &lokiClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		limit:      1000,
+		minWindow:  time.Second,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// fetch calls fn with the coverage and entries of every window of
+// [start, end) in chronological order
+func (c *lokiClient) fetch(ctx context.Context, query string, start, end time.Time, fn func(SourceCoverage, []LogEntry) error) error {
+	entries, err := c.queryRange(ctx, query, start, end)
+	if err != nil {
+		return err
+	}
+
+	window := end.Sub(start)
+	coverage := SourceCoverage{
+		Source:  "loki",
+		Entries: len(entries),
+		Start:   start,
+		End:     end,
+	}
+	if len(entries) >= c.limit {
+		if window/2 >= c.minWindow {
+			mid := start.Add(window / 2)
+			if err := c.fetch(ctx, query, start, mid, fn); err != nil {
+				return err
+			}
+			return c.fetch(ctx, query, mid, end, fn)
+		}
+		fmt.Fprintf(progress, "   ⚠️  Warning: window %s - %s still returns %d lines, data may be incomplete\n",
+			start.Format(time.RFC3339), end.Format(time.RFC3339), len(entries))
+		coverage.Truncated = true
+	}
+
+	fmt.Fprintf(progress, "   ✅ Loaded %d entries for %s - %s\n", len(entries),
+		start.Format(time.RFC3339), end.Format(time.RFC3339))
+	return fn(coverage, entries)
+}
+
+// queryRange performs a single query_range request
+func (c *lokiClient) queryRange(ctx context.Context, query string, start, end time.Time) ([]LogEntry, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	params.Set("limit", strconv.Itoa(c.limit))
+	params.Set("direction", "forward")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if c.orgID != "" {
+		req.Header.Set("X-Scope-OrgID", c.orgID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("querying loki: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result lokiQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unmarshaling loki response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("loki query failed: %s", result.Error)
+	}
+	if result.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("unsupported loki result type %q, expected a log query", result.Data.ResultType)
+	}
+
+	type timedEntry struct {
+		ns    int64
+		entry LogEntry
+	}
+	var timed []timedEntry
+
+	for _, stream := range result.Data.Result {
+		fields := make(map[string]any, len(stream.Stream))
+		for k, v := range stream.Stream {
+			fields[k] = v
+		}
+
+		for _, value := range stream.Values {
+			ns, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing loki timestamp %q: %w", value[0], err)
+			}
+			timed = append(timed, timedEntry{
+				ns: ns,
+				entry: LogEntry{
+					Line:      value[1],
+					Timestamp: time.Unix(0, ns).UTC().Format(time.RFC3339Nano),
+					Fields:    fields,
+				},
+			})
+		}
+	}
+
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].ns < timed[j].ns
+	})
+
+	entries := make([]LogEntry, len(timed))
+	for i, t := range timed {
+		entries[i] = t.entry
+	}
+	return entries, nil
+}
+
+// streamLokiGameData queries Loki and feeds the parsed game events to fn,
+// returning the windows that could not be split below the line limit
+func streamLokiGameData(ctx context.Context, client *lokiClient, query string, start, end time.Time, fn func(GameData) error) ([]SourceCoverage, error) {
+	fmt.Fprintf(progress, "🌐 Querying %s for %s\n", client.baseURL, query)
+
+	var truncated []SourceCoverage
+	total := 0
+	err := client.fetch(ctx, query, start, end, func(window SourceCoverage, entries []LogEntry) error {
+		total += len(entries)
+		if window.Truncated {
+			truncated = append(truncated, window)
+		}
+
+		gameData, err := parseGameData(entries)
+		if err != nil {
+			return err
+		}
+		for _, data := range gameData {
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(progress, "\n📊 Total entries loaded: %d\n\n", total)
+	return truncated, nil
+}
+
+// parseTime accepts RFC 3339 timestamps, "2006-01-02 15:04[:05]" in
+// location and Unix seconds
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
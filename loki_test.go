@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLoki serves query_range requests from a fixed set of log lines,
+// returning at most limit lines of the requested window like Loki does
+type fakeLoki struct {
+	lines    map[int64]string
+	requests int
+	orgIDs   []string
+	status   int
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests++
+	f.orgIDs = append(f.orgIDs, r.Header.Get("X-Scope-OrgID"))
+	if f.status != 0 {
+		http.Error(w, "too many outstanding requests", f.status)
+		return
+	}
+
+	query := r.URL.Query()
+	start, _ := strconv.ParseInt(query.Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(query.Get("end"), 10, 64)
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	var times []int64
+	for ns := range f.lines {
+		if ns >= start && ns < end {
+			times = append(times, ns)
+		}
+	}
+	slices.Sort(times)
+
+	var values [][2]string
+	for _, ns := range times[:min(limit, len(times))] {
+		values = append(values, [2]string{strconv.FormatInt(ns, 10), f.lines[ns]})
+	}
+
+	var response lokiQueryResponse
+	response.Status = "success"
+	response.Data.ResultType = "streams"
+	response.Data.Result = append(response.Data.Result, struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}{Stream: map[string]string{"app": "game"}, Values: values})
+	json.NewEncoder(w).Encode(response)
+}
+
+// betLines returns a bet per offset from start, keyed by Loki time
+func betLines(start time.Time, offsets ...time.Duration) map[int64]string {
+	lines := make(map[int64]string)
+	for i, offset := range offsets {
+		at := start.Add(offset)
+		lines[at.UnixNano()] = fmt.Sprintf(
+			`{"msg":"SendBet","ts":%d,"player_id":"p1","currency":"NGN","round_id":"r%d","bet_id":"b%d","bet":100}`,
+			at.Unix(), i, i)
+	}
+	return lines
+}
+
+func newFakeLoki(t *testing.T, loki *fakeLoki) *lokiClient {
+	t.Helper()
+	progress = io.Discard
+	server := httptest.NewServer(loki)
+	t.Cleanup(server.Close)
+	return newLokiClient(server.URL)
+}
+
+func TestLokiSplitsWindowsAtLimit(t *testing.T) {
+	start := time.Date(2025, 12, 26, 20, 0, 0, 0, time.UTC)
+	var offsets []time.Duration
+	for i := 0; i < 10; i++ {
+		offsets = append(offsets, time.Duration(i)*time.Second)
+	}
+	loki := &fakeLoki{lines: betLines(start, offsets...)}
+	client := newFakeLoki(t, loki)
+	client.limit = 4
+
+	var betIDs []string
+	truncated, err := streamLokiGameData(context.Background(), client, `{app="game"}`, start, start.Add(16*time.Second),
+		func(data GameData) error {
+			betIDs = append(betIDs, data.BetID)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(truncated) != 0 {
+		t.Errorf("got %d truncated windows, want none", len(truncated))
+	}
+	want := "b0 b1 b2 b3 b4 b5 b6 b7 b8 b9"
+	if got := strings.Join(betIDs, " "); got != want {
+		t.Errorf("got bets %s, want %s", got, want)
+	}
+	if loki.requests < 3 {
+		t.Errorf("got %d requests, want the range split into several windows", loki.requests)
+	}
+}
+
+func TestLokiReportsTruncatedWindows(t *testing.T) {
+	start := time.Date(2025, 12, 26, 20, 0, 0, 0, time.UTC)
+	loki := &fakeLoki{lines: betLines(start, 0, 100*time.Millisecond, 200*time.Millisecond, 300*time.Millisecond, 5*time.Second)}
+	client := newFakeLoki(t, loki)
+	client.limit = 3
+	client.minWindow = time.Second
+
+	var bets int
+	truncated, err := streamLokiGameData(context.Background(), client, `{app="game"}`, start, start.Add(8*time.Second),
+		func(data GameData) error {
+			bets++
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(truncated) != 1 {
+		t.Fatalf("got %d truncated windows, want 1", len(truncated))
+	}
+	window := truncated[0]
+	if !window.Truncated || window.Entries != 3 {
+		t.Errorf("got truncated window %+v, want 3 entries flagged truncated", window)
+	}
+	if !window.Start.Equal(start) || window.End.Sub(window.Start) >= 2*client.minWindow {
+		t.Errorf("got window %s - %s, want the first window below twice the minimum", window.Start, window.End)
+	}
+	if bets != 4 {
+		t.Errorf("got %d bets, want the 3 of the truncated window and the last one", bets)
+	}
+}
+
+func TestLokiFailsOnErrorStatus(t *testing.T) {
+	loki := &fakeLoki{status: http.StatusTooManyRequests}
+	client := newFakeLoki(t, loki)
+
+	start := time.Date(2025, 12, 26, 20, 0, 0, 0, time.UTC)
+	_, err := streamLokiGameData(context.Background(), client, `{app="game"}`, start, start.Add(time.Hour),
+		func(GameData) error { return nil })
+	if err == nil {
+		t.Fatal("got no error for a 429 response")
+	}
+	if !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "too many outstanding requests") {
+		t.Errorf("got error %q, want the status and body", err)
+	}
+}
+
+func TestLokiSendsOrgID(t *testing.T) {
+	start := time.Date(2025, 12, 26, 20, 0, 0, 0, time.UTC)
+
+	for _, orgID := range []string{"tenant-1", ""} {
+		loki := &fakeLoki{lines: betLines(start, 0)}
+		client := newFakeLoki(t, loki)
+		client.orgID = orgID
+
+		_, err := streamLokiGameData(context.Background(), client, `{app="game"}`, start, start.Add(time.Hour),
+			func(GameData) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loki.orgIDs) != 1 || loki.orgIDs[0] != orgID {
+			t.Errorf("got X-Scope-OrgID %q, want %q", loki.orgIDs, orgID)
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -39,6 +41,11 @@ type GameData struct {
 
 	Timestamp float64 `json:"ts"`
 
+	// Time is the resolved event time and LogTime the time Loki recorded
+	// the line, zero when unknown
+	Time    time.Time `json:"-"`
+	LogTime time.Time `json:"-"`
+
 	BetID string `json:"bet_id,omitempty"`
 	WinID string `json:"win_id,omitempty"`
 
@@ -47,15 +54,44 @@ type GameData struct {
 	Balance int64 `json:"balance"`
 
 	StepNumber int `json:"step_number"`
+
+	// sourceSeq numbers the bets and wins of a player in the order they
+	// were read, from 1, when a filter may drop some of them
+	sourceSeq int
 }
 
 // Report represents the analysis report
 type Report struct {
-	Summary          Summary               `json:"summary"`
-	PlayerStats      map[string]PlayerStat `json:"player_stats"`
-	GameStats        map[string]GameStat   `json:"game_stats"`
-	TimeStats        []TimeStat            `json:"time_stats"`
-	SuspiciousEvents []SuspiciousEvent     `json:"suspicious_events"`
+	Metadata         *ReportMetadata        `json:"metadata,omitempty"`
+	Currency         string                 `json:"currency,omitempty"`
+	Summary          Summary                `json:"summary"`
+	PlayerStats      map[string]PlayerStat  `json:"player_stats"`
+	GameStats        map[string]GameStat    `json:"game_stats"`
+	OperatorStats    map[string]SegmentStat `json:"operator_stats"`
+	PlatformStats    map[string]SegmentStat `json:"platform_stats"`
+	TimeBucket       string                 `json:"time_bucket,omitempty"`
+	TimeStats        []TimeStat             `json:"time_stats"`
+	Heatmap          *ActivityHeatmap       `json:"heatmap,omitempty"`
+	SuspiciousEvents []SuspiciousEvent      `json:"suspicious_events"`
+	PlayerRisks      []PlayerRisk           `json:"player_risks"`
+	Rounds           *RoundSummary          `json:"rounds,omitempty"`
+	Wallet           *WalletSummary         `json:"wallet,omitempty"`
+	Clusters         []PlayerCluster        `json:"player_clusters,omitempty"`
+	Coverage         *CoverageReport        `json:"coverage,omitempty"`
+	Daily            []DailyReport          `json:"daily,omitempty"`
+	DayOverDay       []DayComparison        `json:"day_over_day,omitempty"`
+
+	// Currencies holds a report per currency when the data is not in a
+	// single currency or is converted to a reporting currency
+	Currencies map[string]Report `json:"currencies,omitempty"`
+
+	// locale is how the amounts and times of the report are formatted
+	locale locale
+}
+
+// money returns the format of the report's amounts
+func (r Report) money() currencyFormat {
+	return r.locale.MinorUnits.currency(r.Currency)
 }
 
 type Summary struct {
@@ -68,21 +104,50 @@ type Summary struct {
 	UniquePlayers  int     `json:"unique_players"`
 	UniqueGames    int     `json:"unique_games"`
 	TimeSpan       string  `json:"time_span"`
+	Incomplete     bool    `json:"incomplete"`
+	DuplicateBets  int     `json:"duplicate_bets"`
+	DuplicateWins  int     `json:"duplicate_wins"`
 }
 
 type PlayerStat struct {
-	PlayerID          string   `json:"player_id"`
-	RTP               float64  `json:"rtp_percentage"`
-	TotalBetAmount    int64    `json:"total_bet_amount"`
-	TotalWinAmount    int64    `json:"total_win_amount"`
-	NetResult         int64    `json:"net_result"`
-	LastBalance       int64    `json:"last_balance"`
-	TotalBets         int      `json:"total_bets"`
-	TotalWins         int      `json:"total_wins"`
-	TopBets           []TopBet `json:"top_bets"`
-	TopWins           []TopWin `json:"top_wins"`
-	MinBetIntervalSec float64  `json:"min_bet_interval_sec,omitempty"`
-	MaxSpinsPerMinute int      `json:"max_spins_per_minute,omitempty"`
+	PlayerID          string     `json:"player_id"`
+	RTP               float64    `json:"rtp_percentage"`
+	TotalBetAmount    int64      `json:"total_bet_amount"`
+	TotalWinAmount    int64      `json:"total_win_amount"`
+	NetResult         int64      `json:"net_result"`
+	LastBalance       int64      `json:"last_balance"`
+	TotalBets         int        `json:"total_bets"`
+	TotalWins         int        `json:"total_wins"`
+	Rounds            int        `json:"rounds"`
+	TopBets           []TopBet   `json:"top_bets"`
+	TopWins           []TopWin   `json:"top_wins"`
+	MinBetIntervalSec float64    `json:"min_bet_interval_sec,omitempty"`
+	MaxSpinsPerMinute int        `json:"max_spins_per_minute,omitempty"`
+	BetTiming         *BetTiming `json:"bet_timing,omitempty"`
+
+	// BalanceBreaks counts balance changes not explained by a bet or win;
+	// UnexplainedBalance is the net amount of those changes
+	BalanceBreaks      int   `json:"balance_breaks,omitempty"`
+	UnexplainedBalance int64 `json:"unexplained_balance_change,omitempty"`
+
+	RTPTest      *RTPTest     `json:"rtp_test,omitempty"`
+	BetPatterns  *BetPatterns `json:"bet_patterns,omitempty"`
+	Hits         *HitStats    `json:"hit_stats,omitempty"`
+	HitTest      *HitRateTest `json:"hit_rate_test,omitempty"`
+	ClusterID    int          `json:"cluster_id,omitempty"`
+	RiskScore    float64      `json:"risk_score,omitempty"`
+	RiskSeverity Severity     `json:"risk_severity,omitempty"`
+
+	// TimestampSkew compares the event times with the Loki timestamps
+	TimestampSkew *TimestampSkew `json:"timestamp_skew,omitempty"`
+
+	BalanceTimeline []BalancePoint `json:"balance_timeline,omitempty"`
+}
+
+// BalancePoint is a sampled player balance
+type BalancePoint struct {
+	Timestamp float64 `json:"ts"`
+	Balance   int64   `json:"balance"`
 }
 
 type GameStat struct {
@@ -92,23 +157,44 @@ type GameStat struct {
 	TotalWinAmount int64   `json:"total_win_amount"`
 	TotalBets      int     `json:"total_bets"`
 	TotalWins      int     `json:"total_wins"`
+	Rounds         int     `json:"rounds"`
 	Players        int     `json:"unique_players"`
-}
 
-type TimeStat struct {
-	Hour           int   `json:"hour"`
-	TotalBets      int   `json:"total_bets"`
-	TotalWins      int   `json:"total_wins"`
-	TotalBetAmount int64 `json:"total_bet_amount"`
-	TotalWinAmount int64 `json:"total_win_amount"`
+	Hits *HitStats `json:"hit_stats,omitempty"`
 }
 
+// EventType identifies the check that raised a suspicious event
+type EventType string
+
+const (
+	EventHighRTP      EventType = "High RTP"
+	EventHighSpinRate EventType = "High Spin Rate"
+)
+
 type SuspiciousEvent struct {
-	Type        string `json:"type"`
-	Description string `json:"description"`
-	PlayerID    string `json:"player_id"`
-	Timestamp   string `json:"timestamp"`
-	Details     string `json:"details"`
+	Type        EventType `json:"type"`
+	Description string    `json:"description"`
+	PlayerID    string    `json:"player_id"`
+	GameID      string    `json:"game_id,omitempty"`
+	OperatorID  string    `json:"operator_id,omitempty"`
+	PlatformID  string    `json:"platform_id,omitempty"`
+	RoundID     string    `json:"round_id,omitempty"`
+
+	// Rule is the name of the rule that raised the event and Score its
+	// severity score multiplied by the rule weight
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Score    float64  `json:"score"`
+
+	// Timestamp is the start of the flagged activity and EndTimestamp its
+	// end when it spans a period
+	Timestamp    string `json:"timestamp"`
+	EndTimestamp string `json:"end_timestamp,omitempty"`
+
+	// Amount is the flagged amount in minor units, such as a balance
+	// discrepancy
+	Amount  int64  `json:"amount,omitempty"`
+	Details string `json:"details"`
 }
 
 type DailyReport struct {
@@ -128,270 +214,391 @@ type TopWin struct {
 	Time    string `json:"time"`
 }
 
-func run() error {
-	// Find all JSON files in current directory
-	files, err := findJSONFiles()
+func run(args []string) error {
+	cfg, err := parseConfig(args, os.Stderr)
 	if err != nil {
-		return fmt.Errorf("finding JSON files: %w", err)
+		return err
 	}
 
-	if len(files) == 0 {
-		return fmt.Errorf("no JSON files found in current directory")
+	// Keep stdout clean for machine-readable output
+	if cfg.Format != formatText && (cfg.Output == "" || cfg.Output == "-") {
+		progress = os.Stderr
 	}
 
-	fmt.Printf("📁 Found %d JSON files to analyze:\n", len(files))
-	for i, file := range files {
-		fmt.Printf("   %d. %s\n", i+1, file)
+	var rates *exchangeRates
+	if cfg.RatesFile != "" {
+		rates, err = loadExchangeRates(cfg.RatesFile, cfg.ReportingCurrency)
+		if err != nil {
+			return err
+		}
 	}
-	fmt.Println()
 
-	logs, err := readMultipleLogFiles(files)
+	rules, err := loadRules(cfg.RulesFile, cfg.Thresholds)
 	if err != nil {
-		return fmt.Errorf("reading logs: %w", err)
+		return err
 	}
 
-	gameData, err := parseGameData(logs)
-	if err != nil {
-		return err
+	analysis := newAnalyzer(rules, rates, cfg.Buckets, cfg.Locale)
+	addData := analysis.add
+
+	// Drop the events not matching the filter before they reach the
+	// analysis, so every statistic and rule sees the same subset
+	var filter *filterStream
+	if cfg.Filter != nil {
+		filter = newFilterStream(cfg.Filter, analysis.add)
+		addData = filter.addData
+		analysis.filteredWallets = true
 	}
 
-	// Detect currency before generating report - fail if not found
-	detectedCurrency := ""
-	for _, data := range gameData {
-		if data.Currency != "" {
-			detectedCurrency = data.Currency
-			break
+	var (
+		files                      []string
+		fileSources, lokiTruncated []SourceCoverage
+	)
+
+	if cfg.Loki.URL != "" {
+		client := newLokiClient(cfg.Loki.URL)
+		client.orgID = cfg.Loki.OrgID
+		client.limit = cfg.Loki.Limit
+		client.minWindow = cfg.Loki.MinWindow
+
+		lokiTruncated, err = streamLokiGameData(context.Background(), client, cfg.Loki.Query, cfg.Loki.From, cfg.Loki.To, addData)
+		if err != nil {
+			return fmt.Errorf("reading loki: %w", err)
 		}
 	}
 
-	if detectedCurrency == "" {
-		return fmt.Errorf("❌ ERROR: No currency information found in logs. Please ensure your logs contain currency field")
-	}
+	if len(cfg.Inputs) > 0 {
+		files, err = resolveInputs(cfg.Inputs, cfg.Recursive)
+		if err != nil {
+			return fmt.Errorf("finding JSON files: %w", err)
+		}
 
-	fmt.Printf("💰 Detected currency: %s\n", detectedCurrency)
+		if len(files) == 0 {
+			return fmt.Errorf("no JSON files found in %s", strings.Join(cfg.Inputs, ", "))
+		}
 
-	report := generateReport(gameData, detectedCurrency)
+		fmt.Fprintf(progress, "📁 Found %d JSON files to analyze:\n", len(files))
+		for i, file := range files {
+			fmt.Fprintf(progress, "   %d. %s\n", i+1, file)
+		}
+		fmt.Fprintln(progress)
 
-	printReport(report, detectedCurrency)
+		fileSources, err = streamGameData(files, cfg.ExportLimit, addData)
+		if err != nil {
+			return fmt.Errorf("reading logs: %w", err)
+		}
+	}
 
-	return nil
-}
+	if filter != nil {
+		filter.flush()
+		fmt.Fprintf(progress, "🔎 Filter %s: %d events match, %d skipped\n", cfg.Filter, filter.matched, filter.skipped)
+		if filter.matched == 0 {
+			return fmt.Errorf("no events match the filter %s", cfg.Filter)
+		}
+	}
 
-func main() {
-	if err := run(); err != nil {
-		log.Fatal(err)
+	// Currencies are detected while streaming - fail if none found
+	currencies := analysis.currencies()
+	if len(currencies) == 0 || (len(currencies) == 1 && currencies[0] == unknownCurrency) {
+		return fmt.Errorf("❌ ERROR: No currency information found in logs. Please ensure your logs contain currency field")
 	}
-}
 
-func findJSONFiles() ([]string, error) {
-	pattern := "*.json"
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		return nil, fmt.Errorf("globbing files: %w", err)
+	fmt.Fprintf(progress, "💰 Detected currencies: %s\n", strings.Join(currencies, ", "))
+	if len(currencies) > 1 && rates == nil {
+		fmt.Fprintf(progress, "   ⚠️  Multiple currencies found - amounts are reported per currency. Use -rates to combine them.\n")
 	}
 
-	// Sort files by name for consistent processing order
-	return files, nil
-}
+	analysis.printDuplicates()
+	report := analysis.build()
 
-func readLogsEntry(fileName string) ([]LogEntry, error) {
-	data, err := os.ReadFile(fileName)
-	if err != nil {
-		return nil, fmt.Errorf("reading file: %w", err)
+	if len(fileSources) > 0 || len(lokiTruncated) > 0 {
+		report.Coverage = buildCoverage(fileSources, cfg.ExportLimit, cfg.MaxGap)
+		for _, window := range lokiTruncated {
+			report.Coverage.Sources = append(report.Coverage.Sources, window)
+			report.Coverage.Incomplete = true
+		}
+		report.Summary.Incomplete = report.Coverage.Incomplete
+		for currency, currencyReport := range report.Currencies {
+			currencyReport.Summary.Incomplete = report.Coverage.Incomplete
+			report.Currencies[currency] = currencyReport
+		}
 	}
 
-	var logs []LogEntry
-	if err := json.Unmarshal(data, &logs); err != nil {
-		return nil, fmt.Errorf("unmarshaling: %w", err)
+	report.Metadata = &ReportMetadata{
+		ToolVersion: version,
+		GeneratedAt: time.Now().In(cfg.Locale.Zones.Default),
+		Currencies:  currencies,
+		TimeZones:   cfg.Locale.Zones.String(),
+		InputFiles:  files,
+		LokiURL:     cfg.Loki.URL,
+		LokiQuery:   cfg.Loki.Query,
+	}
+	if cfg.Filter != nil {
+		report.Metadata.Filter = cfg.Filter.String()
 	}
 
-	return logs, nil
+	return writeReport(cfg, report)
 }
 
-func readMultipleLogFiles(fileNames []string) ([]LogEntry, error) {
-	var allLogs []LogEntry
-
-	for _, fileName := range fileNames {
-		fmt.Printf("📖 Reading %s...\n", fileName)
-		logs, err := readLogsEntry(fileName)
-		if err != nil {
-			fmt.Printf("   ⚠️  Warning: failed to read %s: %v\n", fileName, err)
-			continue
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
 		}
-		fmt.Printf("   ✅ Loaded %d entries from %s\n", len(logs), fileName)
-		allLogs = append(allLogs, logs...)
+		log.Fatal(err)
 	}
-
-	fmt.Printf("\n📊 Total entries loaded: %d\n\n", len(allLogs))
-	return allLogs, nil
 }
 
 func parseGameData(logs []LogEntry) ([]GameData, error) {
 	var gameData []GameData
 
 	for _, logEntry := range logs {
-		if logEntry.Line == "" {
-			continue
+		data, ok, err := parseGameLine(logEntry)
+		if err != nil {
+			return nil, err
 		}
-
-		var data GameData
-		if err := json.Unmarshal([]byte(logEntry.Line), &data); err != nil {
-			return nil, fmt.Errorf("unmarshaling: %w", err)
+		if ok {
+			gameData = append(gameData, data)
 		}
-
-		gameData = append(gameData, data)
 	}
 
 	return gameData, nil
 }
 
-func generateReport(gameData []GameData, currency string) Report {
-	report := Report{
-		PlayerStats:      make(map[string]PlayerStat),
-		GameStats:        make(map[string]GameStat),
-		SuspiciousEvents: []SuspiciousEvent{},
+// parseGameLine decodes the game event carried in a log line. Entries
+// without a line are reported as not ok.
+func parseGameLine(logEntry LogEntry) (GameData, bool, error) {
+	if logEntry.Line == "" {
+		return GameData{}, false, nil
 	}
 
-	var (
-		totalBets      int
-		totalWins      int
-		totalBetAmount int64
-		totalWinAmount int64
-		uniquePlayers          = make(map[string]bool)
-		uniqueGames            = make(map[string]bool)
-		uniqueBetIDs           = make(map[string]bool)
-		uniqueWinIDs           = make(map[string]bool)
-		timeStats              = make(map[int]TimeStat)
-		minTime        float64 = -1
-		maxTime        float64 = 0
-		playerBalances         = make(map[string][]int64)
-		duplicateBets  int     = 0
-		duplicateWins  int     = 0
-		playerBetTimestamps    = make(map[string][]float64)
-	)
-
-	// Process each game data entry
-	for _, data := range gameData {
-		uniquePlayers[data.PlayerID] = true
-		uniqueGames[data.GameID] = true
-
-		// Update min/max time
-		if data.Timestamp > maxTime {
-			maxTime = data.Timestamp
-		}
-		if minTime < 0 || data.Timestamp < minTime {
-			minTime = data.Timestamp
-		}
-
-		// Track balance changes
-		playerBalances[data.PlayerID] = append(playerBalances[data.PlayerID], data.Balance)
+	var data GameData
+	if err := json.Unmarshal([]byte(logEntry.Line), &data); err != nil {
+		return GameData{}, false, fmt.Errorf("unmarshaling: %w", err)
+	}
+	data.resolveTime(logEntry.Timestamp)
 
-		// Parse hour from Unix timestamp (convert to time object first)
-		gameTime := time.Unix(int64(data.Timestamp), 0)
-		hour := gameTime.Hour()
+	return data, true, nil
+}
 
-		// Initialize time stats if not exists
-		if _, exists := timeStats[hour]; !exists {
-			timeStats[hour] = TimeStat{Hour: hour}
-		}
+// reportBuilder aggregates game events one at a time, so a report can be
+// produced without holding the whole dataset in memory
+type reportBuilder struct {
+	rules   *ruleSet
+	buckets bucketing
+	report  Report
+
+	totalBets           int
+	totalWins           int
+	totalBetAmount      int64
+	totalWinAmount      int64
+	uniquePlayers       map[string]bool
+	uniqueGames         map[string]bool
+	gamePlayers         map[string]map[string]bool
+	timeline            *activityTimeline
+	minTime             time.Time
+	maxTime             time.Time
+	playerBetTimestamps map[string][]float64
+	playerBalances      map[string]*balanceSampler
+	operators           map[string]*activityTotals
+	platforms           map[string]*activityTotals
+
+	// The following are nil for day builders
+
+	// days holds one builder per calendar day
+	days map[string]*reportBuilder
+	// rounds groups the events by round
+	rounds *roundSet
+	// wallets holds the balance changes per player
+	wallets *walletLedger
+	// links holds the rooms and hosts of players
+	links *playerLinker
+	// skews holds the differences between ts and the Loki time
+	skews *skewTracker
+
+	// roundList is the reconstructed rounds, available after build
+	roundList []*Round
+}
 
-		// Process bet or win
-		if data.Message == "SendBet" && data.Bet > 0 {
-			// Check if this bet ID was already processed
-			if data.BetID != "" {
-				if uniqueBetIDs[data.BetID] {
-					duplicateBets++
-					fmt.Printf("   ⚠️  Skipping duplicate bet ID: %s\n", data.BetID)
-					continue // Skip duplicate bet
-				}
-				uniqueBetIDs[data.BetID] = true
-			}
+func newReportBuilder(rules *ruleSet, buckets bucketing, loc locale) *reportBuilder {
+	return &reportBuilder{
+		rules:   rules,
+		buckets: buckets,
+		report: Report{
+			PlayerStats:      make(map[string]PlayerStat),
+			GameStats:        make(map[string]GameStat),
+			SuspiciousEvents: []SuspiciousEvent{},
+			locale:           loc,
+		},
+		uniquePlayers:       make(map[string]bool),
+		uniqueGames:         make(map[string]bool),
+		gamePlayers:         make(map[string]map[string]bool),
+		timeline:            newActivityTimeline(buckets, loc.Zones),
+		playerBetTimestamps: make(map[string][]float64),
+		playerBalances:      make(map[string]*balanceSampler),
+		operators:           make(map[string]*activityTotals),
+		platforms:           make(map[string]*activityTotals),
+		days:                make(map[string]*reportBuilder),
+		rounds:              newRoundSet(),
+		wallets:             newWalletLedger(),
+		links:               newPlayerLinker(),
+		skews:               newSkewTracker(),
+	}
+}
 
-			totalBets++
-			totalBetAmount += data.Bet
+// add processes a single unique game data entry
+func (b *reportBuilder) add(data GameData) {
+	b.aggregate(data)
 
-			// Track bet timestamps for spin rate analysis
-			playerBetTimestamps[data.PlayerID] = append(playerBetTimestamps[data.PlayerID], data.Timestamp)
+	if b.rounds != nil {
+		b.rounds.add(data)
+	}
+	if b.wallets != nil {
+		b.wallets.add(data)
+	}
+	if b.links != nil {
+		b.links.add(data)
+	}
+	if b.skews != nil {
+		b.skews.add(data)
+	}
 
-			// Update player stats
-			pStat := report.PlayerStats[data.PlayerID]
-			pStat.PlayerID = data.PlayerID
-			pStat.TotalBets++
-			pStat.TotalBetAmount += data.Bet
-			pStat.LastBalance = data.Balance
+	// Partition by calendar day of the event
+	if b.days != nil && !data.Time.IsZero() {
+		date := b.report.locale.Zones.at(data.Time, data.OperatorID).Format(dateLayout)
+		day, ok := b.days[date]
+		if !ok {
+			// The heatmap covers the whole period only
+			day = newReportBuilder(b.rules, bucketing{Size: b.buckets.Size}, b.report.locale)
+			day.days = nil
+			day.rounds = nil
+			day.wallets = nil
+			day.links = nil
+			day.skews = nil
+			b.days[date] = day
+		}
+		day.aggregate(data)
+	}
+}
 
-			// Track top bets
-			topBet := TopBet{
-				Amount:  data.Bet,
-				RoundID: data.RoundID,
-				Time:    time.Unix(int64(data.Timestamp), 0).Format("2006-01-02 15:04:05"),
-			}
-			pStat.TopBets = append(pStat.TopBets, topBet)
+// aggregate adds a unique game data entry to the statistics
+func (b *reportBuilder) aggregate(data GameData) {
+	report := &b.report
 
-			report.PlayerStats[data.PlayerID] = pStat
+	b.uniquePlayers[data.PlayerID] = true
+	b.uniqueGames[data.GameID] = true
 
-			// Update game stats
-			gStat := report.GameStats[data.GameID]
-			gStat.GameID = data.GameID
-			gStat.TotalBets++
-			gStat.TotalBetAmount += data.Bet
-			if _, exists := report.GameStats[data.GameID]; !exists {
-				gStat.Players = 0
-			}
-			report.GameStats[data.GameID] = gStat
-
-			// Update time stats
-			tStat := timeStats[hour]
-			tStat.TotalBets++
-			tStat.TotalBetAmount += data.Bet
-			timeStats[hour] = tStat
-
-		} else if data.Message == "SendWin" && data.Win > 0 {
-			// Check if this win ID was already processed
-			if data.WinID != "" {
-				if uniqueWinIDs[data.WinID] {
-					duplicateWins++
-					fmt.Printf("   ⚠️  Skipping duplicate win ID: %s\n", data.WinID)
-					continue // Skip duplicate win
-				}
-				uniqueWinIDs[data.WinID] = true
-			}
+	// Count unique players per game
+	if b.gamePlayers[data.GameID] == nil {
+		b.gamePlayers[data.GameID] = make(map[string]bool)
+	}
+	b.gamePlayers[data.GameID][data.PlayerID] = true
 
-			totalWins++
-			totalWinAmount += data.Win
+	// Update min/max time, events without a time take no part
+	if !data.Time.IsZero() {
+		if data.Time.After(b.maxTime) {
+			b.maxTime = data.Time
+		}
+		if b.minTime.IsZero() || data.Time.Before(b.minTime) {
+			b.minTime = data.Time
+		}
+	}
 
-			// Update player stats
-			pStat := report.PlayerStats[data.PlayerID]
-			pStat.TotalWins++
-			pStat.TotalWinAmount += data.Win
-			pStat.LastBalance = data.Balance
+	// Sample balance changes for the balance timeline
+	if data.Message == "SendBet" || data.Message == "SendWin" {
+		sampler := b.playerBalances[data.PlayerID]
+		if sampler == nil {
+			sampler = &balanceSampler{}
+			b.playerBalances[data.PlayerID] = sampler
+		}
+		sampler.add(BalancePoint{Timestamp: data.Timestamp, Balance: data.Balance})
+	}
 
-			// Track top wins
-			topWin := TopWin{
-				Amount:  data.Win,
-				RoundID: data.RoundID,
-				Time:    time.Unix(int64(data.Timestamp), 0).Format("2006-01-02 15:04:05"),
-			}
-			pStat.TopWins = append(pStat.TopWins, topWin)
+	gameTime := b.report.locale.Zones.timestamp(data.Time, data.OperatorID)
+	b.timeline.add(data)
+
+	operator := segmentTotals(b.operators, data.OperatorID)
+	operator.players[data.PlayerID] = true
+	platform := segmentTotals(b.platforms, data.PlatformID)
+	platform.players[data.PlayerID] = true
+
+	// Process bet or win
+	if data.Message == "SendBet" && data.Bet > 0 {
+		b.totalBets++
+		b.totalBetAmount += data.Bet
+		operator.bets++
+		operator.betAmount += data.Bet
+		platform.bets++
+		platform.betAmount += data.Bet
+
+		// Track bet timestamps for spin rate analysis
+		b.playerBetTimestamps[data.PlayerID] = append(b.playerBetTimestamps[data.PlayerID], unixSeconds(data.Time))
+
+		// Update player stats
+		pStat := report.PlayerStats[data.PlayerID]
+		pStat.PlayerID = data.PlayerID
+		pStat.TotalBets++
+		pStat.TotalBetAmount += data.Bet
+		pStat.LastBalance = data.Balance
+
+		// Track top bets
+		topBet := TopBet{
+			Amount:  data.Bet,
+			RoundID: data.RoundID,
+			Time:    gameTime,
+		}
+		pStat.TopBets = insertTop(pStat.TopBets, topBet, 5, func(a, b TopBet) bool {
+			return a.Amount > b.Amount
+		})
 
-			report.PlayerStats[data.PlayerID] = pStat
+		report.PlayerStats[data.PlayerID] = pStat
+
+		// Update game stats
+		gStat := report.GameStats[data.GameID]
+		gStat.GameID = data.GameID
+		gStat.TotalBets++
+		gStat.TotalBetAmount += data.Bet
+		report.GameStats[data.GameID] = gStat
+	} else if data.Message == "SendWin" && data.Win > 0 {
+		b.totalWins++
+		b.totalWinAmount += data.Win
+		operator.wins++
+		operator.winAmount += data.Win
+		platform.wins++
+		platform.winAmount += data.Win
+
+		// Update player stats
+		pStat := report.PlayerStats[data.PlayerID]
+		pStat.TotalWins++
+		pStat.TotalWinAmount += data.Win
+		pStat.LastBalance = data.Balance
+
+		// Track top wins
+		topWin := TopWin{
+			Amount:  data.Win,
+			RoundID: data.RoundID,
+			Time:    gameTime,
+		}
+		pStat.TopWins = insertTop(pStat.TopWins, topWin, 5, func(a, b TopWin) bool {
+			return a.Amount > b.Amount
+		})
 
-			// Update game stats
-			gStat := report.GameStats[data.GameID]
-			gStat.TotalWins++
-			gStat.TotalWinAmount += data.Win
-			report.GameStats[data.GameID] = gStat
+		report.PlayerStats[data.PlayerID] = pStat
 
-			// Update time stats
-			tStat := timeStats[hour]
-			tStat.TotalWins++
-			tStat.TotalWinAmount += data.Win
-			timeStats[hour] = tStat
-		}
+		// Update game stats
+		gStat := report.GameStats[data.GameID]
+		gStat.TotalWins++
+		gStat.TotalWinAmount += data.Win
+		report.GameStats[data.GameID] = gStat
 	}
+}
+
+// build calculates the derived statistics and returns the finished report
+func (b *reportBuilder) build() Report {
+	report := b.report
 
 	// Calculate spin rate per player
-	for playerID, timestamps := range playerBetTimestamps {
+	for playerID, timestamps := range b.playerBetTimestamps {
 		if len(timestamps) < 2 {
 			continue
 		}
@@ -420,50 +627,108 @@ func generateReport(gameData []GameData, currency string) Report {
 		pStat := report.PlayerStats[playerID]
 		pStat.MinBetIntervalSec = minInterval
 		pStat.MaxSpinsPerMinute = maxSpins
+		pStat.BetTiming = analyzeBetTiming(timestamps)
 		report.PlayerStats[playerID] = pStat
 	}
 
+	// Reconstruct rounds from their bet and win events
+	if b.rounds != nil {
+		b.roundList = b.rounds.list()
+		report.Rounds = summarizeRounds(b.roundList, 5)
+
+		for _, round := range b.roundList {
+			if pStat, ok := report.PlayerStats[round.PlayerID]; ok {
+				pStat.Rounds++
+				report.PlayerStats[round.PlayerID] = pStat
+			}
+			if gStat, ok := report.GameStats[round.GameID]; ok {
+				gStat.Rounds++
+				report.GameStats[round.GameID] = gStat
+			}
+		}
+	}
+
+	// Reconcile every player's balance with their bets and wins
+	var walletEvents []SuspiciousEvent
+	if b.wallets != nil {
+		wallet, events, results := b.wallets.reconcile(report.money(), report.locale.Zones)
+		report.Wallet = wallet
+		walletEvents = events
+
+		for playerID, result := range results {
+			if pStat, ok := report.PlayerStats[playerID]; ok {
+				pStat.BalanceBreaks = result.breaks
+				pStat.UnexplainedBalance = result.discrepancy
+				report.PlayerStats[playerID] = pStat
+			}
+		}
+	}
+
+	// Compare the event times with the Loki timestamps
+	if b.skews != nil {
+		for playerID, skew := range b.skews.summarize(report.locale.Zones) {
+			if pStat, ok := report.PlayerStats[playerID]; ok {
+				pStat.TimestampSkew = skew
+				report.PlayerStats[playerID] = pStat
+			}
+		}
+	}
+
 	// Calculate derived stats
 	for playerID, pStat := range report.PlayerStats {
+		if sampler := b.playerBalances[playerID]; sampler != nil {
+			pStat.BalanceTimeline = sampler.timeline()
+		}
 		pStat.NetResult = pStat.TotalWinAmount - pStat.TotalBetAmount
 		if pStat.TotalBetAmount > 0 {
 			pStat.RTP = float64(pStat.TotalWinAmount) / float64(pStat.TotalBetAmount) * 100
 		}
 
-		// Sort and limit top bets (keep top 5)
-		sort.Slice(pStat.TopBets, func(i, j int) bool {
-			return pStat.TopBets[i].Amount > pStat.TopBets[j].Amount
-		})
-		if len(pStat.TopBets) > 5 {
-			pStat.TopBets = pStat.TopBets[:5]
-		}
+		report.PlayerStats[playerID] = pStat
+	}
 
-		// Sort and limit top wins (keep top 5)
-		sort.Slice(pStat.TopWins, func(i, j int) bool {
-			return pStat.TopWins[i].Amount > pStat.TopWins[j].Amount
-		})
-		if len(pStat.TopWins) > 5 {
-			pStat.TopWins = pStat.TopWins[:5]
+	// Test player RTP against the expected return of the games played,
+	// analyze the sequence of their stakes and how often rounds win
+	if b.roundList != nil {
+		for playerID, test := range testPlayerRTP(b.roundList, b.rules.Games) {
+			if pStat, ok := report.PlayerStats[playerID]; ok {
+				pStat.RTPTest = test
+				report.PlayerStats[playerID] = pStat
+			}
+		}
+		for playerID, patterns := range analyzeBetPatterns(b.roundList) {
+			if pStat, ok := report.PlayerStats[playerID]; ok {
+				pStat.BetPatterns = patterns
+				report.PlayerStats[playerID] = pStat
+			}
 		}
 
-		report.PlayerStats[playerID] = pStat
-
-		// Detect suspicious activities
-		if pStat.TotalBets > 100 && pStat.RTP > 150 {
-			report.SuspiciousEvents = append(report.SuspiciousEvents, SuspiciousEvent{
-				Type:        "High RTP",
-				Description: "Player has suspiciously high RTP",
-				PlayerID:    playerID,
-				Details:     fmt.Sprintf("RTP: %.2f%%, Bets: %d", pStat.RTP, pStat.TotalBets),
-			})
+		gameHits, playerHits, hitTests := analyzeHits(b.roundList, b.rules.Games)
+		for playerID, hits := range playerHits {
+			if pStat, ok := report.PlayerStats[playerID]; ok {
+				pStat.Hits = hits
+				pStat.HitTest = hitTests[playerID]
+				report.PlayerStats[playerID] = pStat
+			}
 		}
-		if pStat.MaxSpinsPerMinute > 30 {
-			report.SuspiciousEvents = append(report.SuspiciousEvents, SuspiciousEvent{
-				Type:        "High Spin Rate",
-				Description: "Player is spinning at an abnormally high rate (possible bot)",
-				PlayerID:    playerID,
-				Details:     fmt.Sprintf("Max %d spins/min, min interval between bets: %.2fs", pStat.MaxSpinsPerMinute, pStat.MinBetIntervalSec),
-			})
+		for gameID, hits := range gameHits {
+			if gStat, ok := report.GameStats[gameID]; ok {
+				gStat.Hits = hits
+				report.GameStats[gameID] = gStat
+			}
+		}
+	}
+
+	// Cluster players linked by shared rooms, hosts, bet times or stakes
+	if b.links != nil {
+		report.Clusters = b.links.cluster(b.playerBetTimestamps, b.roundList, report.PlayerStats, report.money())
+		for _, cluster := range report.Clusters {
+			for _, playerID := range cluster.Players {
+				if pStat, ok := report.PlayerStats[playerID]; ok {
+					pStat.ClusterID = cluster.ID
+					report.PlayerStats[playerID] = pStat
+				}
+			}
 		}
 	}
 
@@ -471,86 +736,161 @@ func generateReport(gameData []GameData, currency string) Report {
 		if gStat.TotalBetAmount > 0 {
 			gStat.RTP = float64(gStat.TotalWinAmount) / float64(gStat.TotalBetAmount) * 100
 		}
-		// Count unique players per game
-		playersInGame := make(map[string]bool)
-		for _, data := range gameData {
-			if data.GameID == gameID {
-				playersInGame[data.PlayerID] = true
-			}
-		}
-		gStat.Players = len(playersInGame)
+		gStat.Players = len(b.gamePlayers[gameID])
 		report.GameStats[gameID] = gStat
 	}
 
-	// Convert time stats map to slice and sort
-	for _, tStat := range timeStats {
-		report.TimeStats = append(report.TimeStats, tStat)
+	// Detect suspicious activities
+	report.SuspiciousEvents = detect(b.rules, &detectionData{
+		report:    &report,
+		rounds:    b.roundList,
+		wallet:    walletEvents,
+		operators: b.operators,
+		windowEnd: b.maxTime,
+	})
+
+	// Rank players by the combined score of their events
+	report.PlayerRisks = rankPlayers(report.SuspiciousEvents)
+	for _, risk := range report.PlayerRisks {
+		if pStat, ok := report.PlayerStats[risk.PlayerID]; ok {
+			pStat.RiskScore = risk.Score
+			pStat.RiskSeverity = risk.Severity
+			report.PlayerStats[risk.PlayerID] = pStat
+		}
 	}
-	sort.Slice(report.TimeStats, func(i, j int) bool {
-		return report.TimeStats[i].Hour < report.TimeStats[j].Hour
+
+	// Break the activity down by operator and platform
+	report.OperatorStats = segmentStats(b.operators, report.SuspiciousEvents, func(event SuspiciousEvent) string {
+		return event.OperatorID
 	})
+	report.PlatformStats = segmentStats(b.platforms, report.SuspiciousEvents, func(event SuspiciousEvent) string {
+		return event.PlatformID
+	})
+
+	// Activity over time
+	report.TimeBucket = b.timeline.size.String()
+	report.TimeStats = b.timeline.stats()
+	report.Heatmap = b.timeline.heatmapStats()
 
 	// Calculate summary
 	report.Summary = Summary{
-		TotalBets:      totalBets,
-		TotalWins:      totalWins,
-		TotalBetAmount: totalBetAmount,
-		TotalWinAmount: totalWinAmount,
-		NetResult:      totalWinAmount - totalBetAmount,
-		UniquePlayers:  len(uniquePlayers),
-		UniqueGames:    len(uniqueGames),
+		TotalBets:      b.totalBets,
+		TotalWins:      b.totalWins,
+		TotalBetAmount: b.totalBetAmount,
+		TotalWinAmount: b.totalWinAmount,
+		NetResult:      b.totalWinAmount - b.totalBetAmount,
+		UniquePlayers:  len(b.uniquePlayers),
+		UniqueGames:    len(b.uniqueGames),
 	}
 
-	if totalBetAmount > 0 {
-		report.Summary.RTP = float64(totalWinAmount) /
-			float64(totalBetAmount) * 100
+	if b.totalBetAmount > 0 {
+		report.Summary.RTP = float64(b.totalWinAmount) /
+			float64(b.totalBetAmount) * 100
 	}
 
-	if minTime >= 0 && maxTime > minTime {
-		startTime := time.Unix(int64(minTime), 0)
-		endTime := time.Unix(int64(maxTime), 0)
-		report.Summary.TimeSpan = fmt.Sprintf("%s - %s", startTime.Format("2006-01-02 15:04:05"), endTime.Format("2006-01-02 15:04:05"))
+	if !b.minTime.IsZero() && b.maxTime.After(b.minTime) {
+		startTime := report.locale.Zones.at(b.minTime, "")
+		endTime := report.locale.Zones.at(b.maxTime, "")
+		report.Summary.TimeSpan = fmt.Sprintf("%s - %s", startTime.Format(timeLayout), endTime.Format(timeLayout))
 	}
 
-	// Print duplicate statistics if any found
-	if duplicateBets > 0 || duplicateWins > 0 {
-		fmt.Printf("\n📋 DUPLICATE DETECTION:\n")
-		if duplicateBets > 0 {
-			fmt.Printf("├─ Duplicate bets found and skipped: %d\n", duplicateBets)
-		}
-		if duplicateWins > 0 {
-			fmt.Printf("├─ Duplicate wins found and skipped: %d\n", duplicateWins)
-		}
-		fmt.Printf("└─ Only unique transactions included in analysis\n")
-	} else {
-		fmt.Printf("\n✅ DATA INTEGRITY: No duplicate transactions detected\n")
+	// Build one report per calendar day
+	for date, day := range b.days {
+		report.Daily = append(report.Daily, DailyReport{Date: date, Report: day.build()})
 	}
+	sort.Slice(report.Daily, func(i, j int) bool {
+		return report.Daily[i].Date < report.Daily[j].Date
+	})
+	report.DayOverDay = compareDays(report.Daily)
 
 	return report
 }
 
-func printDailyReport(daily DailyReport, currency string) {
-	fmt.Println("\n" + strings.Repeat("=", 60))
-	fmt.Printf("                    DAILY REPORT - %s\n", daily.Date)
-	fmt.Println(strings.Repeat("=", 60))
+// maxBalancePoints bounds the balance timeline kept per player
+const maxBalancePoints = 200
+
+// balanceSampler keeps an evenly thinned sample of a player's balances, so
+// memory does not grow with the number of events
+type balanceSampler struct {
+	points []BalancePoint
+	stride int
+	seen   int
+}
+
+func (s *balanceSampler) add(point BalancePoint) {
+	if s.stride == 0 {
+		s.stride = 1
+	}
+	s.seen++
+	if (s.seen-1)%s.stride != 0 {
+		return
+	}
+
+	s.points = append(s.points, point)
+	if len(s.points) < maxBalancePoints*2 {
+		return
+	}
+
+	// Drop every other point and sample half as often from now on
+	kept := s.points[:0]
+	for i := 0; i < len(s.points); i += 2 {
+		kept = append(kept, s.points[i])
+	}
+	s.points = kept
+	s.stride *= 2
+}
+
+// timeline returns the sampled balances in chronological order
+func (s *balanceSampler) timeline() []BalancePoint {
+	points := append([]BalancePoint(nil), s.points...)
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Timestamp < points[j].Timestamp
+	})
+	return points
+}
+
+// insertTop inserts item into a list kept sorted by less and trimmed to limit
+func insertTop[T any](list []T, item T, limit int, less func(a, b T) bool) []T {
+	pos := sort.Search(len(list), func(i int) bool {
+		return less(item, list[i])
+	})
+	if pos >= limit {
+		return list
+	}
+
+	list = append(list, item)
+	copy(list[pos+1:], list[pos:])
+	list[pos] = item
+
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+func printDailyReport(w io.Writer, daily DailyReport, currency currencyFormat) {
+	fmt.Fprintln(w, "\n"+strings.Repeat("=", 60))
+	fmt.Fprintf(w, "                    DAILY REPORT - %s\n", daily.Date)
+	fmt.Fprintln(w, strings.Repeat("=", 60))
 
 	report := daily.Report
 
 	// Summary
-	fmt.Println("\n📊 DAILY STATISTICS:")
-	fmt.Printf("├─ Analysis Period: %s\n", report.Summary.TimeSpan)
-	fmt.Printf("├─ Total Bets: %d\n", report.Summary.TotalBets)
-	fmt.Printf("├─ Total Wins: %d\n", report.Summary.TotalWins)
-	fmt.Printf("├─ Total Bet Amount: %s %s\n", formatCurrency(report.Summary.TotalBetAmount), currency)
-	fmt.Printf("├─ Total Win Amount: %s %s\n", formatCurrency(report.Summary.TotalWinAmount), currency)
-	fmt.Printf("├─ Net Result: %s %s\n", formatCurrency(report.Summary.NetResult), currency)
-	fmt.Printf("├─ RTP (Return to Player): %.2f%%\n", report.Summary.RTP)
-	fmt.Printf("├─ Unique Players: %d\n", report.Summary.UniquePlayers)
-	fmt.Printf("└─ Unique Games: %d\n", report.Summary.UniqueGames)
+	fmt.Fprintln(w, "\n📊 DAILY STATISTICS:")
+	fmt.Fprintf(w, "├─ Analysis Period: %s\n", report.Summary.TimeSpan)
+	fmt.Fprintf(w, "├─ Time Zone: %s\n", report.locale.Zones)
+	fmt.Fprintf(w, "├─ Total Bets: %d\n", report.Summary.TotalBets)
+	fmt.Fprintf(w, "├─ Total Wins: %d\n", report.Summary.TotalWins)
+	fmt.Fprintf(w, "├─ Total Bet Amount: %s\n", formatMoney(report.Summary.TotalBetAmount, currency))
+	fmt.Fprintf(w, "├─ Total Win Amount: %s\n", formatMoney(report.Summary.TotalWinAmount, currency))
+	fmt.Fprintf(w, "├─ Net Result: %s\n", formatMoney(report.Summary.NetResult, currency))
+	fmt.Fprintf(w, "├─ RTP (Return to Player): %.2f%%\n", report.Summary.RTP)
+	fmt.Fprintf(w, "├─ Unique Players: %d\n", report.Summary.UniquePlayers)
+	fmt.Fprintf(w, "└─ Unique Games: %d\n", report.Summary.UniqueGames)
 
 	// Top player for the day
 	if len(report.PlayerStats) > 0 {
-		fmt.Println("\n👥 TOP PLAYER OF THE DAY:")
+		fmt.Fprintln(w, "\n👥 TOP PLAYER OF THE DAY:")
 		var topPlayer PlayerStat
 		var topPlayerID string
 		for pid, stat := range report.PlayerStats {
@@ -559,66 +899,65 @@ func printDailyReport(daily DailyReport, currency string) {
 				topPlayerID = pid
 			}
 		}
-		fmt.Printf("Player ID: %s\n", topPlayerID)
-		fmt.Printf("├─ 📊 Activity: %d bets, %d wins\n", topPlayer.TotalBets, topPlayer.TotalWins)
-		fmt.Printf("├─ 💰 Volume: Bet %s %s, Win %s %s\n",
-			formatCurrency(topPlayer.TotalBetAmount), currency, formatCurrency(topPlayer.TotalWinAmount), currency)
-		fmt.Printf("├─ 📉 Net Profit: %s %s (%.2f%%)\n",
-			formatCurrency(topPlayer.NetResult), currency,
-			float64(topPlayer.NetResult)/float64(topPlayer.TotalBetAmount)*100)
-		fmt.Printf("└─ 🎯 RTP: %.2f%%, Current Balance: %s %s\n",
-			topPlayer.RTP, formatCurrency(topPlayer.LastBalance), currency)
+		fmt.Fprintf(w, "Player ID: %s\n", topPlayerID)
+		fmt.Fprintf(w, "├─ 📊 Activity: %d bets, %d wins\n", topPlayer.TotalBets, topPlayer.TotalWins)
+		fmt.Fprintf(w, "├─ 💰 Volume: Bet %s, Win %s\n",
+			formatMoney(topPlayer.TotalBetAmount, currency), formatMoney(topPlayer.TotalWinAmount, currency))
+		profitPercent := float64(0)
+		if topPlayer.TotalBetAmount > 0 {
+			profitPercent = float64(topPlayer.NetResult) / float64(topPlayer.TotalBetAmount) * 100
+		}
+		fmt.Fprintf(w, "├─ 📉 Net Profit: %s (%.2f%%)\n",
+			formatMoney(topPlayer.NetResult, currency), profitPercent)
+		fmt.Fprintf(w, "└─ 🎯 RTP: %.2f%%, Current Balance: %s\n",
+			topPlayer.RTP, formatMoney(topPlayer.LastBalance, currency))
 	}
 
 	// Game performance for the day
-	fmt.Println("\n🎮 GAME PERFORMANCE:")
+	fmt.Fprintln(w, "\n🎮 GAME PERFORMANCE:")
 	for gameID, stat := range report.GameStats {
-		fmt.Printf("Game: %s - RTP: %.2f%%, Volume: %s %s\n",
-			gameID, stat.RTP, formatCurrency(stat.TotalBetAmount), currency)
+		fmt.Fprintf(w, "Game: %s - RTP: %.2f%%, Volume: %s\n",
+			gameID, stat.RTP, formatMoney(stat.TotalBetAmount, currency))
 	}
 
-	fmt.Println(strings.Repeat("-", 60))
+	fmt.Fprintln(w, strings.Repeat("-", 60))
 }
 
-func extractDateFromFilename(filename string) string {
-	// Extract date from filename like "25.12.2025.json" or "26.12.2025(1).json"
-	base := filepath.Base(filename)
-	if strings.Contains(base, ".") {
-		parts := strings.Split(base, ".")
-		if len(parts) >= 3 {
-			return fmt.Sprintf("%s.%s.%s", parts[0], parts[1], parts[2])
-		}
-	}
-	return base
-}
+func printOverallReport(w io.Writer, report Report, currency currencyFormat) {
+	fmt.Fprintln(w, "\n"+strings.Repeat("=", 60))
+	fmt.Fprintln(w, "                    OVERALL SUMMARY REPORT")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
 
-func printOverallReport(report Report) {
-	fmt.Println("\n" + strings.Repeat("=", 60))
-	fmt.Println("                    OVERALL SUMMARY REPORT")
-	fmt.Println(strings.Repeat("=", 60))
-
-	printReport(report, "")
+	printReport(w, report, currency)
 }
 
-func printReport(report Report, currency string) {
-	fmt.Println("\n" + strings.Repeat("=", 60))
-	fmt.Println("                    GAMING LOGS ANALYSIS REPORT")
-	fmt.Println(strings.Repeat("=", 60))
+func printReport(w io.Writer, report Report, currency currencyFormat) {
+	fmt.Fprintln(w, "\n"+strings.Repeat("=", 60))
+	fmt.Fprintln(w, "                    GAMING LOGS ANALYSIS REPORT")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+
+	if report.Coverage != nil {
+		printCoverage(w, report.Coverage, report.locale.Zones)
+	}
 
 	// Summary
-	fmt.Println("\n📊 GENERAL STATISTICS:")
-	fmt.Printf("├─ Analysis Period: %s\n", report.Summary.TimeSpan)
-	fmt.Printf("├─ Total Bets: %d\n", report.Summary.TotalBets)
-	fmt.Printf("├─ Total Wins: %d\n", report.Summary.TotalWins)
-	fmt.Printf("├─ Total Bet Amount: %s %s\n", formatCurrency(report.Summary.TotalBetAmount), currency)
-	fmt.Printf("├─ Total Win Amount: %s %s\n", formatCurrency(report.Summary.TotalWinAmount), currency)
-	fmt.Printf("├─ Net Result: %s %s\n", formatCurrency(report.Summary.NetResult), currency)
-	fmt.Printf("├─ RTP (Return to Player): %.2f%%\n", report.Summary.RTP)
-	fmt.Printf("├─ Unique Players: %d\n", report.Summary.UniquePlayers)
-	fmt.Printf("└─ Unique Games: %d\n", report.Summary.UniqueGames)
+	fmt.Fprintln(w, "\n📊 GENERAL STATISTICS:")
+	if report.Summary.Incomplete {
+		fmt.Fprintf(w, "├─ ⚠️  INCOMPLETE DATA: figures below do not cover the whole period\n")
+	}
+	fmt.Fprintf(w, "├─ Analysis Period: %s\n", report.Summary.TimeSpan)
+	fmt.Fprintf(w, "├─ Time Zone: %s\n", report.locale.Zones)
+	fmt.Fprintf(w, "├─ Total Bets: %d\n", report.Summary.TotalBets)
+	fmt.Fprintf(w, "├─ Total Wins: %d\n", report.Summary.TotalWins)
+	fmt.Fprintf(w, "├─ Total Bet Amount: %s\n", formatMoney(report.Summary.TotalBetAmount, currency))
+	fmt.Fprintf(w, "├─ Total Win Amount: %s\n", formatMoney(report.Summary.TotalWinAmount, currency))
+	fmt.Fprintf(w, "├─ Net Result: %s\n", formatMoney(report.Summary.NetResult, currency))
+	fmt.Fprintf(w, "├─ RTP (Return to Player): %.2f%%\n", report.Summary.RTP)
+	fmt.Fprintf(w, "├─ Unique Players: %d\n", report.Summary.UniquePlayers)
+	fmt.Fprintf(w, "└─ Unique Games: %d\n", report.Summary.UniqueGames)
 
 	// Player stats
-	fmt.Printf("\n👥 PLAYER ANALYSIS (%d unique players):\n", len(report.PlayerStats))
+	fmt.Fprintf(w, "\n👥 PLAYER ANALYSIS (%d unique players):\n", len(report.PlayerStats))
 	type PlayerRank struct {
 		PlayerID string
 		Stat     PlayerStat
@@ -634,9 +973,9 @@ func printReport(report Report, currency string) {
 	// Show top players (max 10)
 	displayCount := min(10, len(playerRanks))
 	for i, pr := range playerRanks[:displayCount] {
-		fmt.Printf("Player #%d: %s\n", i+1, pr.PlayerID)
-		fmt.Printf("├─ 📊 Activity: %d bets, %d wins\n", pr.Stat.TotalBets, pr.Stat.TotalWins)
-		fmt.Printf("├─ 💰 Volume: Bet %s %s, Win %s %s\n", formatCurrency(pr.Stat.TotalBetAmount), currency, formatCurrency(pr.Stat.TotalWinAmount), currency)
+		fmt.Fprintf(w, "Player #%d: %s\n", i+1, pr.PlayerID)
+		fmt.Fprintf(w, "├─ 📊 Activity: %d bets, %d wins\n", pr.Stat.TotalBets, pr.Stat.TotalWins)
+		fmt.Fprintf(w, "├─ 💰 Volume: Bet %s, Win %s\n", formatMoney(pr.Stat.TotalBetAmount, currency), formatMoney(pr.Stat.TotalWinAmount, currency))
 
 		// Profit display in currency and percentage
 		profitPercent := float64(0)
@@ -647,27 +986,75 @@ func printReport(report Report, currency string) {
 		if pr.Stat.NetResult < 0 {
 			profitStatus = "📉"
 		}
-		fmt.Printf("├─ %s Net Profit: %s %s (%.2f%%)\n", profitStatus, formatCurrency(pr.Stat.NetResult), currency, profitPercent)
-		fmt.Printf("├─ 🎯 RTP: %.2f%%, Current Balance: %s %s\n", pr.Stat.RTP, formatCurrency(pr.Stat.LastBalance), currency)
+		fmt.Fprintf(w, "├─ %s Net Profit: %s (%.2f%%)\n", profitStatus, formatMoney(pr.Stat.NetResult, currency), profitPercent)
+		fmt.Fprintf(w, "├─ 🎯 RTP: %.2f%%, Current Balance: %s\n", pr.Stat.RTP, formatMoney(pr.Stat.LastBalance, currency))
 		if pr.Stat.MaxSpinsPerMinute > 0 {
 			spinFlag := ""
-			if pr.Stat.MaxSpinsPerMinute > 30 {
+			if hasSuspiciousEvent(report, pr.PlayerID, EventHighSpinRate) {
 				spinFlag = " ⚠️"
 			}
-			fmt.Printf("├─ ⚡ Spin Rate: max %d spins/min, min interval: %.2fs%s\n", pr.Stat.MaxSpinsPerMinute, pr.Stat.MinBetIntervalSec, spinFlag)
+			fmt.Fprintf(w, "├─ ⚡ Spin Rate: max %d spins/min, min interval: %.2fs%s\n", pr.Stat.MaxSpinsPerMinute, pr.Stat.MinBetIntervalSec, spinFlag)
+		}
+		if timing := pr.Stat.BetTiming; timing != nil {
+			timingFlag := ""
+			if hasSuspiciousEvent(report, pr.PlayerID, EventBotTiming) {
+				timingFlag = " ⚠️"
+			}
+			fmt.Fprintf(w, "├─ ⏱️  Bet Timing: mean %.2fs, stddev %.2fs, CV %.2f, entropy %.2f bits, periodicity %.2f%s\n",
+				timing.MeanSec, timing.StdDevSec, timing.CV, timing.EntropyBits, timing.Periodicity, timingFlag)
+			printIntervalHistogram(w, timing.Histogram)
+		}
+		if test := pr.Stat.RTPTest; test != nil {
+			fmt.Fprintf(w, "├─ 📐 RTP Test: expected %.2f%% over %d rounds, z = %.2f, p = %.2g\n",
+				test.ExpectedRTP, test.Rounds, test.ZScore, test.PValue)
+		}
+		if hits := pr.Stat.Hits; hits != nil {
+			hitFlag := ""
+			if hasSuspiciousEvent(report, pr.PlayerID, EventHitRateOutlier) {
+				hitFlag = " ⚠️"
+			}
+			fmt.Fprintf(w, "├─ 🎯 Hit Rate: %.2f%% of %d rounds", hits.HitRate, hits.Rounds)
+			if test := pr.Stat.HitTest; test != nil {
+				fmt.Fprintf(w, " vs expected %.2f%%, z = %.2f, p = %.2g", test.ExpectedHitRate, test.ZScore, test.PValue)
+			}
+			fmt.Fprintf(w, ", max multiplier %.2fx%s\n", hits.MaxMultiplier, hitFlag)
+		}
+		if patterns := pr.Stat.BetPatterns; patterns != nil {
+			if summary := patternSummary(patterns, currency); summary != "" {
+				patternFlag := ""
+				if hasSuspiciousEvent(report, pr.PlayerID, EventMartingale) ||
+					hasSuspiciousEvent(report, pr.PlayerID, EventStepUpBigWin) ||
+					hasSuspiciousEvent(report, pr.PlayerID, EventRepeatingStake) {
+					patternFlag = " ⚠️"
+				}
+				fmt.Fprintf(w, "├─ 🎰 Bet Patterns: %s%s\n", summary, patternFlag)
+			}
+		}
+		if skew := pr.Stat.TimestampSkew; skew != nil && hasSuspiciousEvent(report, pr.PlayerID, EventTimestampMismatch) {
+			fmt.Fprintf(w, "├─ 🕰️ Timestamp Skew: max %+.3fs, mean %.3fs over %d entries ⚠️\n", skew.MaxSkewSec, skew.MeanSec, skew.Entries)
+		}
+		if pr.Stat.ClusterID > 0 {
+			fmt.Fprintf(w, "├─ 🕸️ Linked Accounts: cluster #%d\n", pr.Stat.ClusterID)
+		}
+		if pr.Stat.RiskScore > 0 {
+			fmt.Fprintf(w, "├─ 🎯 Risk Score: %.1f (%s)\n", pr.Stat.RiskScore, pr.Stat.RiskSeverity)
+		}
+		if pr.Stat.BalanceBreaks > 0 {
+			fmt.Fprintf(w, "├─ 🧾 Wallet: %d balance breaks, unexplained change %s ⚠️\n",
+				pr.Stat.BalanceBreaks, formatMoney(pr.Stat.UnexplainedBalance, currency))
 		}
 
 		// Top bets (only if they exist)
 		if len(pr.Stat.TopBets) > 0 {
-			fmt.Printf("├─ 🎲 Largest Bets: ")
+			fmt.Fprintf(w, "├─ 🎲 Largest Bets: ")
 			topBetCount := min(3, len(pr.Stat.TopBets))
 			for j, bet := range pr.Stat.TopBets[:topBetCount] {
 				if j > 0 {
-					fmt.Printf(", ")
+					fmt.Fprintf(w, ", ")
 				}
-				fmt.Printf("%s %s", formatCurrency(bet.Amount), currency)
+				fmt.Fprint(w, formatMoney(bet.Amount, currency))
 			}
-			fmt.Printf("\n")
+			fmt.Fprintf(w, "\n")
 		}
 
 		// Top wins (only if they exist and > 0)
@@ -680,92 +1067,120 @@ func printReport(report Report, currency string) {
 		}
 
 		if hasWins {
-			fmt.Printf("└─ 🏆 Biggest Wins: ")
+			fmt.Fprintf(w, "└─ 🏆 Biggest Wins: ")
 			topWinCount := min(3, len(pr.Stat.TopWins))
 			winCount := 0
 			for _, win := range pr.Stat.TopWins[:topWinCount] {
 				if win.Amount > 0 {
 					if winCount > 0 {
-						fmt.Printf(", ")
+						fmt.Fprintf(w, ", ")
 					}
-					fmt.Printf("%s %s", formatCurrency(win.Amount), currency)
+					fmt.Fprint(w, formatMoney(win.Amount, currency))
 					winCount++
 				}
 			}
-			fmt.Printf("\n")
+			fmt.Fprintf(w, "\n")
 		} else {
-			fmt.Printf("└─ 🏆 No wins recorded\n")
+			fmt.Fprintf(w, "└─ 🏆 No wins recorded\n")
 		}
 
 		// Add spacing between players if there are multiple
 		if len(playerRanks) > 1 && i < displayCount-1 {
-			fmt.Printf("\n")
+			fmt.Fprintf(w, "\n")
 		}
 	}
 
 	// Game stats
-	fmt.Println("\n🎮 GAME STATISTICS:")
+	fmt.Fprintln(w, "\n🎮 GAME STATISTICS:")
 	for gameID, stat := range report.GameStats {
-		fmt.Printf("Game: %s\n", gameID)
-		fmt.Printf("├─ Bets: %d, Wins: %d\n", stat.TotalBets, stat.TotalWins)
-		fmt.Printf("├─ Bet Volume: %s %s\n", formatCurrency(stat.TotalBetAmount), currency)
-		fmt.Printf("├─ Win Volume: %s %s\n", formatCurrency(stat.TotalWinAmount), currency)
-		fmt.Printf("├─ RTP: %.2f%%\n", stat.RTP)
-		fmt.Printf("└─ Players: %d\n", stat.Players)
+		fmt.Fprintf(w, "Game: %s\n", gameID)
+		fmt.Fprintf(w, "├─ Rounds: %d, Bets: %d, Wins: %d\n", stat.Rounds, stat.TotalBets, stat.TotalWins)
+		fmt.Fprintf(w, "├─ Bet Volume: %s\n", formatMoney(stat.TotalBetAmount, currency))
+		fmt.Fprintf(w, "├─ Win Volume: %s\n", formatMoney(stat.TotalWinAmount, currency))
+		fmt.Fprintf(w, "├─ RTP: %.2f%%\n", stat.RTP)
+		if stat.Hits != nil {
+			printHitStats(w, stat.Hits)
+		}
+		fmt.Fprintf(w, "└─ Players: %d\n", stat.Players)
 	}
 
-	// Time stats
-	fmt.Println("\n⏰ HOURLY ACTIVITY:")
-	for _, tStat := range report.TimeStats {
-		if tStat.TotalBets > 0 {
-			fmt.Printf("%02d:00 - Bets: %4d, Wins: %4d, Volume: %s %s\n",
-				tStat.Hour, tStat.TotalBets, tStat.TotalWins, formatCurrency(tStat.TotalBetAmount), currency)
-		}
+	if len(report.OperatorStats) > 0 {
+		printSegments(w, "🏢 OPERATOR STATISTICS", "Operator", report.OperatorStats, currency)
+	}
+	if len(report.PlatformStats) > 0 {
+		printSegments(w, "🖥️  PLATFORM STATISTICS", "Platform", report.PlatformStats, currency)
+	}
+
+	if report.Rounds != nil {
+		printRoundSummary(w, report.Rounds, currency)
+	}
+
+	if report.Wallet != nil {
+		printWalletSummary(w, report.Wallet, currency)
+	}
+
+	if len(report.Clusters) > 0 {
+		printClusters(w, report.Clusters, currency, 10)
+	}
+
+	printTimeline(w, report, currency)
+	if report.Heatmap != nil {
+		printHeatmap(w, report.Heatmap, currency, 3)
+	}
+
+	if len(report.PlayerRisks) > 0 {
+		printRiskRanking(w, report.PlayerRisks, 10)
 	}
 
 	// Suspicious events
 	if len(report.SuspiciousEvents) > 0 {
-		fmt.Println("\n🚨 SUSPICIOUS ACTIVITY:")
+		fmt.Fprintln(w, "\n🚨 SUSPICIOUS ACTIVITY:")
 		for i, event := range report.SuspiciousEvents {
-			fmt.Printf("%d. %s\n", i+1, event.Type)
-			fmt.Printf("   ├─ Player: %s\n", event.PlayerID)
-			fmt.Printf("   ├─ Description: %s\n", event.Description)
-			fmt.Printf("   └─ Details: %s\n", event.Details)
+			fmt.Fprintf(w, "%d. %s [%s, score %.1f, rule %s]\n", i+1, event.Type, event.Severity, event.Score, event.Rule)
+			switch {
+			case event.PlayerID != "":
+				fmt.Fprintf(w, "   ├─ Player: %s\n", event.PlayerID)
+			case event.GameID != "":
+				fmt.Fprintf(w, "   ├─ Game: %s\n", event.GameID)
+			case event.OperatorID != "":
+				fmt.Fprintf(w, "   ├─ Operator: %s\n", event.OperatorID)
+			}
+			if event.RoundID != "" {
+				fmt.Fprintf(w, "   ├─ Round: %s (game %s)\n", event.RoundID, event.GameID)
+			}
+			if event.Timestamp != "" {
+				if event.EndTimestamp != "" {
+					fmt.Fprintf(w, "   ├─ Time: %s - %s\n", event.Timestamp, event.EndTimestamp)
+				} else {
+					fmt.Fprintf(w, "   ├─ Time: %s\n", event.Timestamp)
+				}
+			}
+			fmt.Fprintf(w, "   ├─ Description: %s\n", event.Description)
+			fmt.Fprintf(w, "   └─ Details: %s\n", event.Details)
 		}
 	} else {
-		fmt.Println("\n✅ GAME INTEGRITY STATUS:")
-		fmt.Printf("├─ No suspicious activity detected\n")
-		fmt.Printf("├─ All player RTP values are within normal ranges\n")
-		fmt.Printf("├─ No unusual betting patterns identified\n")
-		fmt.Printf("├─ Overall RTP: %.2f%% (within expected range)\n", report.Summary.RTP)
-		fmt.Printf("└─ Game appears to be operating normally\n")
-	}
-
-	fmt.Println("\n" + strings.Repeat("=", 60))
-	fmt.Printf("                     END OF REPORT (%s)\n", currency)
-	fmt.Println(strings.Repeat("=", 60))
-}
-
-func formatCurrency(amount int64) string {
-	str := strconv.FormatInt(amount, 10)
-	n := len(str)
-	if n <= 3 {
-		return str
+		fmt.Fprintln(w, "\n✅ GAME INTEGRITY STATUS:")
+		fmt.Fprintf(w, "├─ No suspicious activity detected\n")
+		fmt.Fprintf(w, "├─ All player RTP values are within normal ranges\n")
+		fmt.Fprintf(w, "├─ No unusual betting patterns identified\n")
+		fmt.Fprintf(w, "├─ Overall RTP: %.2f%% (within expected range)\n", report.Summary.RTP)
+		fmt.Fprintf(w, "└─ Game appears to be operating normally\n")
 	}
 
-	var result strings.Builder
-	for i, digit := range str {
-		if i > 0 && (n-i)%3 == 0 {
-			result.WriteString(",")
-		}
-		result.WriteRune(digit)
+	fmt.Fprintln(w, "\n"+strings.Repeat("=", 60))
+	if report.Summary.Incomplete {
+		fmt.Fprintf(w, "              END OF REPORT (%s) - INCOMPLETE DATA\n", currency.code)
+	} else {
+		fmt.Fprintf(w, "                     END OF REPORT (%s)\n", currency.code)
 	}
-	return result.String()
+	fmt.Fprintln(w, strings.Repeat("=", 60))
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+func hasSuspiciousEvent(report Report, playerID string, eventType EventType) bool {
+	for _, event := range report.SuspiciousEvents {
+		if event.PlayerID == playerID && event.Type == eventType {
+			return true
+		}
 	}
-	return b
+	return false
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultMinorUnitExponent is used for currencies that are not listed in
+// iso4217Exponents, e.g. NGN amounts are logged in kobo
+const defaultMinorUnitExponent = 2
+
+// iso4217Exponents lists the ISO 4217 currencies whose minor unit exponent
+// differs from the default
+var iso4217Exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// minorUnitOverrides holds user supplied exponents. Keys are either a
+// currency ("UGX") which changes how amounts in that currency are read, or
+// an operator and currency ("op1:NGN") for operators that log amounts at a
+// different scale; such amounts are rescaled to the currency's exponent
+// when they are read.
+type minorUnitOverrides map[string]int
+
+func (o minorUnitOverrides) String() string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", key, o[key])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a comma-separated list of CUR=exp or OPERATOR:CUR=exp pairs
+func (o minorUnitOverrides) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, exp, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid minor unit override %q, expected CUR=exp or OPERATOR:CUR=exp", pair)
+		}
+		exponent, err := strconv.Atoi(strings.TrimSpace(exp))
+		if err != nil || exponent < 0 || exponent > 8 {
+			return fmt.Errorf("invalid minor unit exponent in %q", pair)
+		}
+
+		key = strings.TrimSpace(key)
+		if operator, currency, ok := strings.Cut(key, ":"); ok {
+			key = operator + ":" + strings.ToUpper(currency)
+		} else {
+			key = strings.ToUpper(key)
+		}
+		o[key] = exponent
+	}
+	return nil
+}
+
+// exponent returns the number of minor unit digits of a currency
+func (o minorUnitOverrides) exponent(currency string) int {
+	currency = strings.ToUpper(currency)
+	if exponent, ok := o[currency]; ok {
+		return exponent
+	}
+	if exponent, ok := iso4217Exponents[currency]; ok {
+		return exponent
+	}
+	return defaultMinorUnitExponent
+}
+
+// normalize rescales the amounts of operators with an exponent override to
+// the standard exponent of the currency
+func (o minorUnitOverrides) normalize(data GameData) GameData {
+	if len(o) == 0 {
+		return data
+	}
+
+	exponent, ok := o[data.OperatorID+":"+strings.ToUpper(data.Currency)]
+	if !ok {
+		return data
+	}
+
+	shift := o.exponent(data.Currency) - exponent
+	if shift == 0 {
+		return data
+	}
+
+	data.Bet = scaleAmount(data.Bet, shift)
+	data.Win = scaleAmount(data.Win, shift)
+	data.Balance = scaleAmount(data.Balance, shift)
+	return data
+}
+
+// scaleAmount multiplies amount by 10^shift, rounding to the nearest unit
+func scaleAmount(amount int64, shift int) int64 {
+	return int64(math.Round(float64(amount) * math.Pow10(shift)))
+}
+
+// currencyFormat is how the amounts of a currency are formatted: its code,
+// empty when amounts of several currencies are mixed, and exponent
+type currencyFormat struct {
+	code     string
+	exponent int
+}
+
+// currency returns the format of a currency with the overrides applied
+func (o minorUnitOverrides) currency(code string) currencyFormat {
+	return currencyFormat{code: code, exponent: o.exponent(code)}
+}
+
+// formatMoney formats a minor-unit amount in major units with thousands
+// separators, e.g. 100000 NGN becomes "1,000.00 NGN"
+func formatMoney(amount int64, currency currencyFormat) string {
+	formatted := formatAmount(amount, currency.exponent)
+	if currency.code == "" {
+		return formatted
+	}
+	return formatted + " " + currency.code
+}
+
+func formatAmount(amount int64, exponent int) string {
+	sign := ""
+	abs := uint64(amount)
+	if amount < 0 {
+		sign = "-"
+		abs = -abs
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-exponent], digits[len(digits)-exponent:]
+
+	var result strings.Builder
+	result.WriteString(sign)
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			result.WriteString(",")
+		}
+		result.WriteRune(digit)
+	}
+	if exponent > 0 {
+		result.WriteString(".")
+		result.WriteString(fraction)
+	}
+	return result.String()
+}
+
+// formatMajorUnits converts a minor-unit amount to a plain decimal string
+// in major units without separators, e.g. 100050 with exponent 2 becomes
+// "1000.50"
+func formatMajorUnits(amount int64, exponent int) string {
+	return strings.ReplaceAll(formatAmount(amount, exponent), ",", "")
+}
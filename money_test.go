@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatMoney(t *testing.T) {
+	overrides := minorUnitOverrides{}
+	if err := overrides.Set("UGX=2,op1:NGN=3"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{100000, "NGN", "1,000.00 NGN"},
+		{5, "NGN", "0.05 NGN"},
+		{0, "NGN", "0.00 NGN"},
+		{-5, "NGN", "-0.05 NGN"},
+		{-123456789, "NGN", "-1,234,567.89 NGN"},
+		{math.MinInt64, "NGN", "-92,233,720,368,547,758.08 NGN"},
+		{math.MaxInt64, "NGN", "92,233,720,368,547,758.07 NGN"},
+		{1234567, "JPY", "1,234,567 JPY"},
+		{-1000, "JPY", "-1,000 JPY"},
+		{1234567, "KWD", "1,234.567 KWD"},
+		{-5, "KWD", "-0.005 KWD"},
+		{12345, "kwd", "12.345 kwd"},
+		{12345, "UGX", "123.45 UGX"},
+		{12345, "", "123.45"},
+	} {
+		if got := formatMoney(test.amount, overrides.currency(test.currency)); got != test.want {
+			t.Errorf("formatMoney(%d, %s) = %q, want %q", test.amount, test.currency, got, test.want)
+		}
+	}
+}
+
+func TestMinorUnitOverridesNormalize(t *testing.T) {
+	overrides := minorUnitOverrides{}
+	if err := overrides.Set("op1:NGN=3, op2:jpy=2, op3:KWD=1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		operator, currency string
+		amount, want       int64
+	}{
+		// Operators logging at another scale are rescaled to the currency's
+		{"op1", "NGN", 12345, 1235},
+		{"op1", "NGN", -12345, -1235},
+		{"op2", "JPY", 12345, 123},
+		{"op3", "KWD", 12345, 1234500},
+		{"op1", "ngn", 1000, 100},
+		// Other operators and currencies keep their amounts
+		{"op2", "NGN", 12345, 12345},
+		{"op1", "KES", 12345, 12345},
+	} {
+		data := overrides.normalize(GameData{
+			OperatorID: test.operator,
+			Currency:   test.currency,
+			Bet:        test.amount,
+			Win:        test.amount,
+			Balance:    test.amount,
+		})
+		if data.Bet != test.want || data.Win != test.want || data.Balance != test.want {
+			t.Errorf("%s %d %s: got bet %d, win %d, balance %d, want %d", test.operator, test.amount, test.currency,
+				data.Bet, data.Win, data.Balance, test.want)
+		}
+	}
+}
+
+func TestMinorUnitOverridesRejectInvalid(t *testing.T) {
+	for _, value := range []string{"NGN", "NGN=x", "NGN=-1", "NGN=9"} {
+		if err := (minorUnitOverrides{}).Set(value); err == nil {
+			t.Errorf("Set(%q): got no error", value)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// progress receives loading and integrity messages. It is redirected to
+// stderr when a machine-readable report is written to stdout.
+var progress io.Writer = os.Stdout
+
+// ReportMetadata describes how a report was produced
+type ReportMetadata struct {
+	ToolVersion string    `json:"tool_version"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Currencies  []string  `json:"currencies"`
+	TimeZones   string    `json:"time_zones"`
+
+	// Filter describes the events analyzed when not all of them are
+	Filter string `json:"filter,omitempty"`
+
+	InputFiles []string `json:"input_files,omitempty"`
+	LokiURL    string   `json:"loki_url,omitempty"`
+	LokiQuery  string   `json:"loki_query,omitempty"`
+}
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+func writeJSONReport(w io.Writer, report Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+	return nil
+}
+
+// writeReport renders the report in the configured format to the
+// configured destination
+func writeReport(cfg config, report Report) error {
+	out := io.Writer(os.Stdout)
+	var file *os.File
+	if cfg.Output != "" && cfg.Output != "-" {
+		var err error
+		file, err = os.Create(cfg.Output)
+		if err != nil {
+			return fmt.Errorf("creating output: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch cfg.Format {
+	case formatJSON:
+		if err := writeJSONReport(out, report); err != nil {
+			return err
+		}
+	case formatHTML:
+		if err := writeHTMLReport(out, report); err != nil {
+			return err
+		}
+	default:
+		printTextReport(out, report, cfg.Daily)
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("closing output: %w", err)
+		}
+		fmt.Fprintf(progress, "\n📝 Report written to %s\n", cfg.Output)
+	}
+
+	if cfg.CSVDir != "" {
+		if err := writeCSVReport(cfg.CSVDir, report); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printTextReport(w io.Writer, report Report, daily bool) {
+	if report.Metadata != nil && report.Metadata.Filter != "" {
+		fmt.Fprintf(w, "\n🔎 FILTER: %s - only matching events are analyzed\n", report.Metadata.Filter)
+	}
+
+	if len(report.Currencies) == 0 {
+		printCurrencyReport(w, report, daily)
+		return
+	}
+
+	// Coverage applies to all currencies, print it once
+	if report.Coverage != nil {
+		printCoverage(w, report.Coverage, report.locale.Zones)
+		report.Coverage = nil
+	}
+
+	for _, currencyReport := range currencyReports(report) {
+		fmt.Fprintln(w, "\n"+strings.Repeat("#", 60))
+		fmt.Fprintf(w, "                    CURRENCY: %s\n", currencyReport.Currency)
+		fmt.Fprintln(w, strings.Repeat("#", 60))
+		printCurrencyReport(w, currencyReport, daily)
+	}
+
+	fmt.Fprintln(w, "\n"+strings.Repeat("#", 60))
+	if report.Currency == "" {
+		fmt.Fprintf(w, "No reporting currency configured - amounts in %d currencies\n", len(report.Currencies))
+		fmt.Fprintf(w, "are not combined. Use -rates to convert them.\n")
+		fmt.Fprintln(w, strings.Repeat("#", 60))
+		return
+	}
+
+	fmt.Fprintf(w, "           ALL CURRENCIES (converted to %s)\n", report.Currency)
+	fmt.Fprintln(w, strings.Repeat("#", 60))
+	printCurrencyReport(w, report, daily)
+}
+
+// printCurrencyReport prints a single-currency report, optionally preceded
+// by its daily breakdown
+func printCurrencyReport(w io.Writer, report Report, daily bool) {
+	if !daily || len(report.Daily) == 0 {
+		printReport(w, report, report.money())
+		return
+	}
+
+	for _, day := range report.Daily {
+		printDailyReport(w, day, report.money())
+	}
+	printDayOverDay(w, report.DayOverDay, report.money())
+	printOverallReport(w, report, report.money())
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bet pattern findings
+const (
+	EventMartingale     EventType = "Martingale Betting"
+	EventStepUpBigWin   EventType = "Stake Step-up Before Big Win"
+	EventRepeatingStake EventType = "Rigid Bet Sequence"
+)
+
+// Definitions used by the bet pattern analysis
+const (
+	// A stake is doubled when the next bet is 1.9 to 2.1 times the last
+	doublingMin = 1.9
+	doublingMax = 2.1
+
+	// A step-up is a bet at least stepUpFactor times the median of the
+	// previous stepUpWindow bets, and a big win pays bigWinMultiplier
+	// times the bet or more
+	stepUpFactor     = 3
+	stepUpWindow     = 10
+	bigWinMultiplier = 10
+
+	// Repeating sequences are searched with cycles of 2 to maxCycleLength
+	// bets
+	maxCycleLength = 8
+
+	maxEvidenceRounds = 10
+)
+
+// BetPatterns summarizes the sequence of a player's stakes. The rounds
+// listed are the evidence for each pattern.
+type BetPatterns struct {
+	Rounds int `json:"rounds"`
+
+	// LossesFollowed counts lost rounds followed by another round and
+	// DoubledAfterLoss those where the next stake was doubled
+	LossesFollowed       int      `json:"losses_followed"`
+	DoubledAfterLoss     int      `json:"doubled_after_loss"`
+	LongestDoublingChain int      `json:"longest_doubling_chain"`
+	DoublingChainRounds  []string `json:"doubling_chain_rounds,omitempty"`
+
+	StepUps            int      `json:"step_ups"`
+	BigWins            int      `json:"big_wins"`
+	StepUpBigWins      int      `json:"step_up_big_wins"`
+	StepUpBigWinRounds []string `json:"step_up_big_win_rounds,omitempty"`
+
+	// RepeatCycle is the longest stretch of stakes repeating a cycle of at
+	// least two different amounts, RepeatCycles the number of cycles
+	RepeatCycle  []int64  `json:"repeat_cycle,omitempty"`
+	RepeatCycles int      `json:"repeat_cycles,omitempty"`
+	RepeatRounds []string `json:"repeat_rounds,omitempty"`
+}
+
+// analyzeBetPatterns runs the pattern analysis for every player over their
+// rounds in chronological order
+func analyzeBetPatterns(rounds []*Round) map[string]*BetPatterns {
+	players := make(map[string][]*Round)
+	for _, round := range rounds {
+		if round.TotalBet > 0 {
+			players[round.PlayerID] = append(players[round.PlayerID], round)
+		}
+	}
+
+	patterns := make(map[string]*BetPatterns, len(players))
+	for playerID, playerRounds := range players {
+		p := &BetPatterns{Rounds: len(playerRounds)}
+		p.findDoubling(playerRounds)
+		p.findStepUps(playerRounds)
+		p.findRepeats(playerRounds)
+		patterns[playerID] = p
+	}
+	return patterns
+}
+
+func (p *BetPatterns) findDoubling(rounds []*Round) {
+	chain, chainStart := 0, 0
+
+	for i := 1; i < len(rounds); i++ {
+		prev, cur := rounds[i-1], rounds[i]
+		lost := prev.TotalWin < prev.TotalBet
+		ratio := float64(cur.TotalBet) / float64(prev.TotalBet)
+		doubled := lost && ratio >= doublingMin && ratio <= doublingMax
+
+		if lost {
+			p.LossesFollowed++
+		}
+		if !doubled {
+			chain = 0
+			continue
+		}
+
+		p.DoubledAfterLoss++
+		if chain == 0 {
+			chainStart = i - 1
+		}
+		chain++
+		if chain > p.LongestDoublingChain {
+			p.LongestDoublingChain = chain
+			p.DoublingChainRounds = roundIDs(rounds[chainStart : i+1])
+		}
+	}
+}
+
+func (p *BetPatterns) findStepUps(rounds []*Round) {
+	for i, round := range rounds {
+		bigWin := round.Multiplier >= bigWinMultiplier
+		if bigWin {
+			p.BigWins++
+		}
+		if i == 0 {
+			continue
+		}
+
+		previous := make([]float64, 0, stepUpWindow)
+		for _, prev := range rounds[max(0, i-stepUpWindow):i] {
+			previous = append(previous, float64(prev.TotalBet))
+		}
+		if float64(round.TotalBet) < stepUpFactor*median(previous) {
+			continue
+		}
+
+		p.StepUps++
+		if bigWin {
+			p.StepUpBigWins++
+			if len(p.StepUpBigWinRounds) < maxEvidenceRounds {
+				p.StepUpBigWinRounds = append(p.StepUpBigWinRounds, round.RoundID)
+			}
+		}
+	}
+}
+
+func (p *BetPatterns) findRepeats(rounds []*Round) {
+	bestLength, bestCycle, bestEnd := 0, 0, 0
+
+	for cycle := 2; cycle <= maxCycleLength; cycle++ {
+		run := 0
+		for i := cycle; i < len(rounds); i++ {
+			if rounds[i].TotalBet != rounds[i-cycle].TotalBet {
+				run = 0
+				continue
+			}
+			run++
+
+			// The stretch covers the first cycle and the run repeating it
+			length := run + cycle
+			if length <= bestLength || length < 2*cycle {
+				continue
+			}
+			start := i - length + 1
+			if !hasDistinctStakes(rounds[start : start+cycle]) {
+				continue
+			}
+			bestLength, bestCycle, bestEnd = length, cycle, i
+		}
+	}
+
+	if bestCycle == 0 {
+		return
+	}
+
+	start := bestEnd - bestLength + 1
+	for _, round := range rounds[start : start+bestCycle] {
+		p.RepeatCycle = append(p.RepeatCycle, round.TotalBet)
+	}
+	p.RepeatCycles = bestLength / bestCycle
+	p.RepeatRounds = roundIDs(rounds[start : bestEnd+1])
+}
+
+func hasDistinctStakes(rounds []*Round) bool {
+	for _, round := range rounds[1:] {
+		if round.TotalBet != rounds[0].TotalBet {
+			return true
+		}
+	}
+	return false
+}
+
+// roundIDs returns the IDs of the rounds, keeping the first and last when
+// there are more than maxEvidenceRounds
+func roundIDs(rounds []*Round) []string {
+	ids := make([]string, 0, min(len(rounds), maxEvidenceRounds))
+	for i, round := range rounds {
+		if len(rounds) > maxEvidenceRounds && i == maxEvidenceRounds-1 {
+			ids = append(ids, rounds[len(rounds)-1].RoundID)
+			break
+		}
+		ids = append(ids, round.RoundID)
+	}
+	return ids
+}
+
+// patternSummary describes the noteworthy patterns of a player in one line
+func patternSummary(p *BetPatterns, currency currencyFormat) string {
+	var parts []string
+	if p.DoubledAfterLoss > 0 {
+		parts = append(parts, fmt.Sprintf("doubled after %d/%d losses (longest chain %d)",
+			p.DoubledAfterLoss, p.LossesFollowed, p.LongestDoublingChain))
+	}
+	if p.StepUpBigWins > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d step-ups won %dx+", p.StepUpBigWins, p.StepUps, bigWinMultiplier))
+	}
+	if p.RepeatCycles > 0 {
+		parts = append(parts, fmt.Sprintf("cycle %s repeated %d times", formatStakes(p.RepeatCycle, currency), p.RepeatCycles))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatStakes(stakes []int64, currency currencyFormat) string {
+	formatted := make([]string, len(stakes))
+	for i, stake := range stakes {
+		formatted[i] = formatAmount(stake, currency.exponent)
+	}
+	return "[" + strings.Join(formatted, " → ") + "]"
+}
+
+// patternDetector flags one bet pattern from the analysis of each player
+type patternDetector struct {
+	rule  Rule
+	check func(p *BetPatterns, rule Rule, currency currencyFormat) (SuspiciousEvent, bool)
+}
+
+func (d patternDetector) Rule() Rule {
+	rule := d.rule
+	rule.Params = make(map[string]float64, len(d.rule.Params))
+	for name, value := range d.rule.Params {
+		rule.Params[name] = value
+	}
+	return rule
+}
+
+func (d patternDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (d patternDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	type playerRound struct{ playerID, roundID string }
+	rounds := make(map[playerRound]*Round, len(data.rounds))
+	for _, round := range data.rounds {
+		rounds[playerRound{round.PlayerID, round.RoundID}] = round
+	}
+
+	var events []SuspiciousEvent
+
+	for _, playerID := range sortedKeys(data.report.PlayerStats) {
+		patterns := data.report.PlayerStats[playerID].BetPatterns
+		if patterns == nil {
+			continue
+		}
+		event, ok := d.check(patterns, rule, data.report.money())
+		if !ok {
+			continue
+		}
+		event.PlayerID = playerID
+
+		// Locate the event at its first evidence round
+		if round, ok := rounds[playerRound{playerID, event.RoundID}]; ok {
+			event.GameID = round.GameID
+			event.OperatorID = round.OperatorID
+			event.PlatformID = round.PlatformID
+			event.Timestamp = data.report.locale.Zones.timestamp(round.StartTime, round.OperatorID)
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// martingaleDetector flags players doubling their stake after at least
+// min_share of their losses, with a chain of min_chain doublings in a row
+var martingaleDetector = patternDetector{
+	rule: Rule{
+		Name: "martingale", Enabled: true, Severity: SeverityMedium, Scope: ScopePlayer,
+		Params: map[string]float64{"min_losses": 10, "min_share": 0.6, "min_chain": 3},
+	},
+	check: func(p *BetPatterns, rule Rule, currency currencyFormat) (SuspiciousEvent, bool) {
+		if p.LossesFollowed < int(rule.param("min_losses")) ||
+			float64(p.DoubledAfterLoss) < rule.param("min_share")*float64(p.LossesFollowed) ||
+			p.LongestDoublingChain < int(rule.param("min_chain")) {
+			return SuspiciousEvent{}, false
+		}
+
+		return SuspiciousEvent{
+			Type:        EventMartingale,
+			Description: "Player doubles the stake after losses (martingale)",
+			RoundID:     p.DoublingChainRounds[0],
+			Details: fmt.Sprintf("Doubled after %d of %d losses, longest chain %d doublings: rounds %s",
+				p.DoubledAfterLoss, p.LossesFollowed, p.LongestDoublingChain, strings.Join(p.DoublingChainRounds, ", ")),
+		}, true
+	},
+}
+
+// stepUpDetector flags players whose sudden stake increases win big at
+// least min_hit_share of the time, in at least min_wins rounds
+var stepUpDetector = patternDetector{
+	rule: Rule{
+		Name: "step_up_before_win", Enabled: true, Severity: SeverityHigh, Scope: ScopePlayer,
+		Params: map[string]float64{"min_wins": 2, "min_hit_share": 0.5},
+	},
+	check: func(p *BetPatterns, rule Rule, currency currencyFormat) (SuspiciousEvent, bool) {
+		if p.StepUpBigWins < int(rule.param("min_wins")) ||
+			float64(p.StepUpBigWins) < rule.param("min_hit_share")*float64(p.StepUps) {
+			return SuspiciousEvent{}, false
+		}
+
+		return SuspiciousEvent{
+			Type:        EventStepUpBigWin,
+			Description: "Player raises the stake right before big wins",
+			RoundID:     p.StepUpBigWinRounds[0],
+			Details: fmt.Sprintf("%d of %d step-ups (%dx the recent median stake) won %dx or more, %d big wins overall: rounds %s",
+				p.StepUpBigWins, p.StepUps, stepUpFactor, bigWinMultiplier, p.BigWins, strings.Join(p.StepUpBigWinRounds, ", ")),
+		}, true
+	},
+}
+
+// repeatingStakeDetector flags players repeating a cycle of different
+// stakes at least min_cycles times in a row
+var repeatingStakeDetector = patternDetector{
+	rule: Rule{
+		Name: "repeating_stakes", Enabled: true, Severity: SeverityMedium, Scope: ScopePlayer,
+		Params: map[string]float64{"min_cycles": 5},
+	},
+	check: func(p *BetPatterns, rule Rule, currency currencyFormat) (SuspiciousEvent, bool) {
+		if p.RepeatCycles < int(rule.param("min_cycles")) {
+			return SuspiciousEvent{}, false
+		}
+
+		return SuspiciousEvent{
+			Type:        EventRepeatingStake,
+			Description: "Player's stakes repeat a rigid sequence (possible script)",
+			RoundID:     p.RepeatRounds[0],
+			Details: fmt.Sprintf("Stakes %s repeated %d times in a row: rounds %s",
+				formatStakes(p.RepeatCycle, currency), p.RepeatCycles, strings.Join(p.RepeatRounds, ", ")),
+		}, true
+	},
+}
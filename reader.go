@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// streamLogEntries decodes a Loki export (a top-level JSON array) element by
+// element, so memory usage does not depend on the size of the export
+func streamLogEntries(r io.Reader, fn func(LogEntry) error) (int, error) {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, fmt.Errorf("reading array start: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return 0, fmt.Errorf("expected top-level JSON array, got %v", tok)
+	}
+
+	count := 0
+	for dec.More() {
+		var entry LogEntry
+		if err := dec.Decode(&entry); err != nil {
+			return count, fmt.Errorf("unmarshaling entry %d: %w", count+1, err)
+		}
+		count++
+
+		if err := fn(entry); err != nil {
+			return count, err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return count, fmt.Errorf("reading array end: %w", err)
+	}
+
+	return count, nil
+}
+
+func streamLogFile(fileName string, fn func(LogEntry) error) (int, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return 0, fmt.Errorf("reading file: %w", err)
+	}
+	defer file.Close()
+
+	return streamLogEntries(bufio.NewReaderSize(file, 1<<20), fn)
+}
+
+// streamGameData reads every file and feeds the parsed game events to fn,
+// returning the coverage of each file. Files that cannot be read are
+// reported and skipped, while errors returned by fn or malformed log lines
+// abort the whole run.
+func streamGameData(fileNames []string, exportLimit int, fn func(GameData) error) ([]SourceCoverage, error) {
+	var sources []SourceCoverage
+	total := 0
+
+	for _, fileName := range fileNames {
+		fmt.Fprintf(progress, "📖 Reading %s...\n", fileName)
+
+		tracker := coverageTracker{coverage: SourceCoverage{Source: fileName}}
+		var handlerErr error
+		count, err := streamLogFile(fileName, func(entry LogEntry) error {
+			data, ok, err := parseGameLine(entry)
+			if err != nil {
+				handlerErr = fmt.Errorf("%s: %w", fileName, err)
+				return handlerErr
+			}
+			tracker.add(entry, data, ok)
+			if !ok {
+				return nil
+			}
+			if err := fn(data); err != nil {
+				handlerErr = err
+				return err
+			}
+			return nil
+		})
+		total += count
+		sources = append(sources, tracker.coverage)
+
+		if handlerErr != nil {
+			return nil, handlerErr
+		}
+		if err != nil {
+			fmt.Fprintf(progress, "   ⚠️  Warning: failed to read %s after %d entries: %v\n", fileName, count, err)
+			continue
+		}
+		fmt.Fprintf(progress, "   ✅ Loaded %d entries from %s\n", count, fileName)
+		if exportLimit > 0 && count == exportLimit {
+			fmt.Fprintf(progress, "   ⚠️  Found %d entries - possible incomplete data\n", count)
+		}
+	}
+
+	fmt.Fprintf(progress, "\n📊 Total entries loaded: %d\n\n", total)
+	return sources, nil
+}
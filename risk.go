@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// PlayerRisk combines the suspicious events raised for a player
+type PlayerRisk struct {
+	PlayerID string   `json:"player_id"`
+	Score    float64  `json:"score"`
+	Severity Severity `json:"severity"`
+	Events   int      `json:"events"`
+
+	// Rules counts the events per rule
+	Rules map[string]int `json:"rules"`
+}
+
+// rankPlayers combines the event scores per player and returns the players
+// with the highest risk first. Each rule contributes its highest event
+// score, growing with the logarithm of its event count, so a rule firing
+// many times does not outweigh several different rules.
+func rankPlayers(events []SuspiciousEvent) []PlayerRisk {
+	type ruleScore struct {
+		max   float64
+		count int
+	}
+	players := make(map[string]map[string]*ruleScore)
+	risks := make(map[string]*PlayerRisk)
+
+	for _, event := range events {
+		if event.PlayerID == "" {
+			continue
+		}
+
+		risk, ok := risks[event.PlayerID]
+		if !ok {
+			risk = &PlayerRisk{PlayerID: event.PlayerID, Rules: make(map[string]int)}
+			risks[event.PlayerID] = risk
+			players[event.PlayerID] = make(map[string]*ruleScore)
+		}
+		risk.Events++
+		risk.Rules[event.Rule]++
+		if severityRank(event.Severity) > severityRank(risk.Severity) {
+			risk.Severity = event.Severity
+		}
+
+		score := players[event.PlayerID][event.Rule]
+		if score == nil {
+			score = &ruleScore{}
+			players[event.PlayerID][event.Rule] = score
+		}
+		score.max = math.Max(score.max, event.Score)
+		score.count++
+	}
+
+	ranking := make([]PlayerRisk, 0, len(risks))
+	for playerID, risk := range risks {
+		for _, score := range players[playerID] {
+			risk.Score += score.max * (1 + math.Log(float64(score.count)))
+		}
+		ranking = append(ranking, *risk)
+	}
+
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Score != ranking[j].Score {
+			return ranking[i].Score > ranking[j].Score
+		}
+		return ranking[i].PlayerID < ranking[j].PlayerID
+	})
+	return ranking
+}
+
+func printRiskRanking(w io.Writer, risks []PlayerRisk, limit int) {
+	fmt.Fprintln(w, "\n🎯 PLAYER RISK RANKING:")
+
+	count := min(limit, len(risks))
+	for i, risk := range risks[:count] {
+		rules := make([]string, 0, len(risk.Rules))
+		for _, rule := range sortedKeys(risk.Rules) {
+			rules = append(rules, fmt.Sprintf("%s ×%d", rule, risk.Rules[rule]))
+		}
+
+		branch := "├─"
+		if i == count-1 {
+			branch = "└─"
+		}
+		fmt.Fprintf(w, "%s #%d %s - score %.1f (%s), %d events: %s\n", branch, i+1,
+			risk.PlayerID, risk.Score, risk.Severity, risk.Events, strings.Join(rules, ", "))
+	}
+	if len(risks) > count {
+		fmt.Fprintf(w, "   ... and %d more flagged players\n", len(risks)-count)
+	}
+}
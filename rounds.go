@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// RoundEvent is a bet or win belonging to a round
+type RoundEvent struct {
+	Message   string  `json:"msg"`
+	ID        string  `json:"id,omitempty"`
+	Step      int     `json:"step_number"`
+	Timestamp float64 `json:"ts"`
+	Amount    int64   `json:"amount"`
+	Balance   int64   `json:"balance"`
+	PlayerID  string  `json:"player_id"`
+	GameID    string  `json:"game_id"`
+
+	// Time is the resolved event time and LogTime the time Loki recorded
+	// it, zero when unknown
+	Time    time.Time `json:"-"`
+	LogTime time.Time `json:"-"`
+}
+
+// BetWinPair links a win to the bet it settles. Bet is nil for a win that
+// has no preceding bet in its round.
+type BetWinPair struct {
+	Bet *RoundEvent `json:"bet"`
+	Win RoundEvent  `json:"win"`
+}
+
+// Round is a game round reconstructed from its bet and win events
+type Round struct {
+	RoundID    string `json:"round_id"`
+	PlayerID   string `json:"player_id"`
+	GameID     string `json:"game_id"`
+	OperatorID string `json:"operator_id,omitempty"`
+	PlatformID string `json:"platform_id,omitempty"`
+
+	// Events are ordered by step number, then by time
+	Events []RoundEvent  `json:"events"`
+	Pairs  []BetWinPair  `json:"pairs"`
+	Bets   []*RoundEvent `json:"-"`
+	Wins   []*RoundEvent `json:"-"`
+
+	TotalBet    int64   `json:"total_bet"`
+	TotalWin    int64   `json:"total_win"`
+	Multiplier  float64 `json:"multiplier"`
+	Start       float64 `json:"start_ts"`
+	End         float64 `json:"end_ts"`
+	DurationSec float64 `json:"duration_sec"`
+
+	// StartTime and EndTime are the times of the first and last event
+	StartTime time.Time `json:"-"`
+	EndTime   time.Time `json:"-"`
+
+	// Complete rounds have a bet and every win is paired with a bet
+	Complete bool `json:"complete"`
+}
+
+// Open reports whether the round has a bet but was never settled
+func (r *Round) Open() bool {
+	return len(r.Bets) > 0 && len(r.Wins) == 0
+}
+
+// RoundSummary aggregates the reconstructed rounds of a report
+type RoundSummary struct {
+	TotalRounds          int     `json:"total_rounds"`
+	CompleteRounds       int     `json:"complete_rounds"`
+	OpenRounds           int     `json:"open_rounds"`
+	IncompleteRounds     int     `json:"incomplete_rounds"`
+	AvgDurationSec       float64 `json:"avg_duration_sec"`
+	AvgMultiplier        float64 `json:"avg_multiplier"`
+	MaxMultiplier        float64 `json:"max_multiplier"`
+	MaxMultiplierRoundID string  `json:"max_multiplier_round_id,omitempty"`
+	TopRounds            []Round `json:"top_rounds,omitempty"`
+}
+
+// roundSet groups bet and win events by round while data is streamed
+type roundSet struct {
+	rounds map[string]*Round
+}
+
+// roundKey identifies the round of an event. Round ids are only unique per
+// operator and player, so different operators may reuse them.
+func roundKey(data GameData) string {
+	return data.OperatorID + "/" + data.PlayerID + "/" + data.RoundID
+}
+
+func newRoundSet() *roundSet {
+	return &roundSet{rounds: make(map[string]*Round)}
+}
+
+func (s *roundSet) add(data GameData) {
+	if data.RoundID == "" {
+		return
+	}
+
+	event := RoundEvent{
+		Message:   data.Message,
+		Step:      data.StepNumber,
+		Timestamp: data.Timestamp,
+		Balance:   data.Balance,
+		PlayerID:  data.PlayerID,
+		GameID:    data.GameID,
+		Time:      data.Time,
+		LogTime:   data.LogTime,
+	}
+	switch data.Message {
+	case "SendBet":
+		event.ID = data.BetID
+		event.Amount = data.Bet
+	case "SendWin":
+		event.ID = data.WinID
+		event.Amount = data.Win
+	default:
+		return
+	}
+
+	key := roundKey(data)
+	round, ok := s.rounds[key]
+	if !ok {
+		round = &Round{RoundID: data.RoundID, PlayerID: data.PlayerID, GameID: data.GameID, OperatorID: data.OperatorID, PlatformID: data.PlatformID}
+		s.rounds[key] = round
+	}
+	round.Events = append(round.Events, event)
+}
+
+// list orders the events of every round, pairs wins with bets and returns
+// the rounds in chronological order
+func (s *roundSet) list() []*Round {
+	rounds := make([]*Round, 0, len(s.rounds))
+	for _, round := range s.rounds {
+		reconstructRound(round)
+		rounds = append(rounds, round)
+	}
+
+	sort.Slice(rounds, func(i, j int) bool {
+		a, b := rounds[i], rounds[j]
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		if a.RoundID != b.RoundID {
+			return a.RoundID < b.RoundID
+		}
+		if a.OperatorID != b.OperatorID {
+			return a.OperatorID < b.OperatorID
+		}
+		return a.PlayerID < b.PlayerID
+	})
+	return rounds
+}
+
+func reconstructRound(round *Round) {
+	sort.SliceStable(round.Events, func(i, j int) bool {
+		a, b := round.Events[i], round.Events[j]
+		if a.Step != b.Step {
+			return a.Step < b.Step
+		}
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if before, ok := loggedBefore(a.LogTime, b.LogTime); ok {
+			return before
+		}
+		// A bet and its win logged with the same step and time
+		return a.Message == "SendBet" && b.Message != "SendBet"
+	})
+
+	round.Bets, round.Wins, round.Pairs = nil, nil, nil
+	round.TotalBet, round.TotalWin = 0, 0
+	round.StartTime, round.EndTime = time.Time{}, time.Time{}
+
+	// Pair every win with the latest bet before it
+	var lastBet *RoundEvent
+	paired := true
+	for i := range round.Events {
+		event := &round.Events[i]
+
+		if round.StartTime.IsZero() || event.Time.Before(round.StartTime) {
+			round.StartTime = event.Time
+		}
+		if event.Time.After(round.EndTime) {
+			round.EndTime = event.Time
+		}
+
+		switch event.Message {
+		case "SendBet":
+			round.Bets = append(round.Bets, event)
+			round.TotalBet += event.Amount
+			lastBet = event
+		case "SendWin":
+			round.Wins = append(round.Wins, event)
+			round.TotalWin += event.Amount
+			round.Pairs = append(round.Pairs, BetWinPair{Bet: lastBet, Win: *event})
+			if lastBet == nil {
+				paired = false
+			}
+		}
+	}
+
+	round.Start, round.End = unixSeconds(round.StartTime), unixSeconds(round.EndTime)
+	round.DurationSec = round.EndTime.Sub(round.StartTime).Seconds()
+	round.Multiplier = 0
+	if round.TotalBet > 0 {
+		round.Multiplier = float64(round.TotalWin) / float64(round.TotalBet)
+	}
+	round.Complete = len(round.Bets) > 0 && len(round.Wins) > 0 && paired
+}
+
+// summarizeRounds aggregates rounds and keeps the rounds with the highest
+// multipliers
+func summarizeRounds(rounds []*Round, top int) *RoundSummary {
+	if len(rounds) == 0 {
+		return nil
+	}
+
+	summary := &RoundSummary{TotalRounds: len(rounds)}
+	var (
+		totalDuration   float64
+		totalMultiplier float64
+		settled         int
+	)
+
+	for _, round := range rounds {
+		switch {
+		case round.Complete:
+			summary.CompleteRounds++
+		case round.Open():
+			summary.OpenRounds++
+		default:
+			summary.IncompleteRounds++
+		}
+
+		if !round.Complete {
+			continue
+		}
+		settled++
+		totalDuration += round.DurationSec
+		totalMultiplier += round.Multiplier
+		if round.Multiplier > summary.MaxMultiplier {
+			summary.MaxMultiplier = round.Multiplier
+			summary.MaxMultiplierRoundID = round.RoundID
+		}
+		summary.TopRounds = insertTop(summary.TopRounds, *round, top, func(a, b Round) bool {
+			return a.Multiplier > b.Multiplier
+		})
+	}
+
+	if settled > 0 {
+		summary.AvgDurationSec = totalDuration / float64(settled)
+		summary.AvgMultiplier = totalMultiplier / float64(settled)
+	}
+
+	return summary
+}
+
+func printRoundSummary(w io.Writer, summary *RoundSummary, currency currencyFormat) {
+	fmt.Fprintln(w, "\n🎲 ROUND ANALYSIS:")
+	fmt.Fprintf(w, "├─ Rounds: %d (complete: %d, open: %d, incomplete: %d)\n",
+		summary.TotalRounds, summary.CompleteRounds, summary.OpenRounds, summary.IncompleteRounds)
+	fmt.Fprintf(w, "├─ Avg Duration: %.2fs, Avg Multiplier: %.2fx\n", summary.AvgDurationSec, summary.AvgMultiplier)
+
+	if len(summary.TopRounds) == 0 {
+		fmt.Fprintf(w, "└─ No completed rounds\n")
+		return
+	}
+
+	fmt.Fprintf(w, "└─ Highest Multipliers:\n")
+	for i, round := range summary.TopRounds {
+		branch := "├─"
+		if i == len(summary.TopRounds)-1 {
+			branch = "└─"
+		}
+		fmt.Fprintf(w, "   %s %.2fx - round %s, player %s, bet %s, win %s\n", branch,
+			round.Multiplier, round.RoundID, round.PlayerID,
+			formatMoney(round.TotalBet, currency), formatMoney(round.TotalWin, currency))
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundSetKeepsReusedRoundIDsApart(t *testing.T) {
+	start := time.Date(2025, 12, 26, 20, 0, 0, 0, time.UTC)
+	rounds := newRoundSet()
+	for _, data := range []GameData{
+		{Message: "SendBet", OperatorID: "op1", PlayerID: "p1", RoundID: "r1", Bet: 100, Time: start},
+		{Message: "SendBet", OperatorID: "op2", PlayerID: "p2", RoundID: "r1", Bet: 100, Time: start},
+		{Message: "SendWin", OperatorID: "op1", PlayerID: "p1", RoundID: "r1", Win: 300, Time: start.Add(time.Second)},
+		{Message: "SendWin", OperatorID: "op2", PlayerID: "p2", RoundID: "r1", Win: 50, Time: start.Add(time.Second)},
+	} {
+		rounds.add(data)
+	}
+
+	list := rounds.list()
+	if len(list) != 2 {
+		t.Fatalf("got %d rounds, want one per operator", len(list))
+	}
+	for i, want := range []struct {
+		operatorID string
+		multiplier float64
+	}{{"op1", 3}, {"op2", 0.5}} {
+		round := list[i]
+		if round.OperatorID != want.operatorID || !round.Complete || round.Multiplier != want.multiplier {
+			t.Errorf("got round %s of %s, complete %t, multiplier %.2f, want %s with %.2f",
+				round.RoundID, round.OperatorID, round.Complete, round.Multiplier, want.operatorID, want.multiplier)
+		}
+	}
+}
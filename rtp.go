@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// EventRTPAnomaly flags a player whose RTP is improbably high
+const EventRTPAnomaly EventType = "RTP Anomaly"
+
+// minPopulationRounds is the number of rounds of other players needed to
+// estimate the expected RTP or volatility of a game
+const minPopulationRounds = 30
+
+// GameProfile is the expected return of a game. RTP and HitRate are in
+// percent and StdDev is the standard deviation of the win multiplier of a
+// round; zero values are estimated from the other players of the game.
+type GameProfile struct {
+	RTP     float64 `json:"rtp"`
+	StdDev  float64 `json:"std_dev"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// RTPTest compares a player's RTP with what the games played would return
+// on average. PValue is the probability of an RTP at least this high by
+// chance.
+type RTPTest struct {
+	Rounds      int     `json:"rounds"`
+	ObservedRTP float64 `json:"observed_rtp_percentage"`
+	ExpectedRTP float64 `json:"expected_rtp_percentage"`
+	ZScore      float64 `json:"z_score"`
+	PValue      float64 `json:"p_value"`
+}
+
+// rtpSums accumulates the bets and wins of rounds for mean and variance
+// estimates
+type rtpSums struct {
+	rounds int
+	bet    float64
+	win    float64
+	bet2   float64
+	win2   float64
+	winBet float64
+}
+
+func (s *rtpSums) add(bet, win float64) {
+	s.rounds++
+	s.bet += bet
+	s.win += win
+	s.bet2 += bet * bet
+	s.win2 += win * win
+	s.winBet += win * bet
+}
+
+func (s rtpSums) minus(o rtpSums) rtpSums {
+	return rtpSums{
+		rounds: s.rounds - o.rounds,
+		bet:    s.bet - o.bet,
+		win:    s.win - o.win,
+		bet2:   s.bet2 - o.bet2,
+		win2:   s.win2 - o.win2,
+		winBet: s.winBet - o.winBet,
+	}
+}
+
+// variance estimates the variance of the win multiplier around mean,
+// weighting rounds by the square of their bet
+func (s rtpSums) variance(mean float64) float64 {
+	if s.bet2 == 0 {
+		return 0
+	}
+	return math.Max((s.win2-2*mean*s.winBet+mean*mean*s.bet2)/s.bet2, 0)
+}
+
+// testPlayerRTP runs an RTP test per player. Each round is treated as an
+// independent draw of its game's win multiplier, so a player's total win
+// is compared with the expected win of their bets using a normal
+// approximation. Games whose expectation cannot be determined are left out
+// of the player's test.
+func testPlayerRTP(rounds []*Round, profiles map[string]GameProfile) map[string]*RTPTest {
+	games := make(map[string]*rtpSums)
+	playerGames := make(map[string]map[string]*rtpSums)
+
+	for _, round := range rounds {
+		if round.TotalBet <= 0 {
+			continue
+		}
+		bet, win := float64(round.TotalBet), float64(round.TotalWin)
+
+		if games[round.GameID] == nil {
+			games[round.GameID] = &rtpSums{}
+		}
+		games[round.GameID].add(bet, win)
+
+		if playerGames[round.PlayerID] == nil {
+			playerGames[round.PlayerID] = make(map[string]*rtpSums)
+		}
+		if playerGames[round.PlayerID][round.GameID] == nil {
+			playerGames[round.PlayerID][round.GameID] = &rtpSums{}
+		}
+		playerGames[round.PlayerID][round.GameID].add(bet, win)
+	}
+
+	tests := make(map[string]*RTPTest)
+	for playerID, perGame := range playerGames {
+		var (
+			observed    rtpSums
+			expectedWin float64
+			winVariance float64
+		)
+
+		for gameID, sums := range perGame {
+			mean, variance, ok := gameExpectation(profiles[gameID], games[gameID].minus(*sums))
+			if !ok {
+				continue
+			}
+			observed.rounds += sums.rounds
+			observed.bet += sums.bet
+			observed.win += sums.win
+			expectedWin += mean * sums.bet
+			winVariance += variance * sums.bet2
+		}
+
+		if observed.bet == 0 || winVariance == 0 {
+			continue
+		}
+
+		z := (observed.win - expectedWin) / math.Sqrt(winVariance)
+		tests[playerID] = &RTPTest{
+			Rounds:      observed.rounds,
+			ObservedRTP: observed.win / observed.bet * 100,
+			ExpectedRTP: expectedWin / observed.bet * 100,
+			ZScore:      z,
+			PValue:      0.5 * math.Erfc(z/math.Sqrt2),
+		}
+	}
+
+	return tests
+}
+
+// gameExpectation returns the mean and variance of a game's win multiplier
+// from its profile, falling back to the rounds of the other players
+func gameExpectation(profile GameProfile, others rtpSums) (mean, variance float64, ok bool) {
+	population := others.rounds >= minPopulationRounds && others.bet > 0
+
+	switch {
+	case profile.RTP > 0:
+		mean = profile.RTP / 100
+	case population:
+		mean = others.win / others.bet
+	default:
+		return 0, 0, false
+	}
+
+	switch {
+	case profile.StdDev > 0:
+		variance = profile.StdDev * profile.StdDev
+	case population:
+		variance = others.variance(mean)
+	default:
+		return 0, 0, false
+	}
+
+	return mean, variance, true
+}
+
+// rtpAnomalyDetector flags players whose RTP is above expectation with a
+// p-value below 1 - confidence over at least min_rounds rounds
+type rtpAnomalyDetector struct{}
+
+func (rtpAnomalyDetector) Rule() Rule {
+	return Rule{
+		Name:     "rtp_anomaly",
+		Enabled:  true,
+		Severity: SeverityHigh,
+		Scope:    ScopePlayer,
+		Params:   map[string]float64{"confidence": 0.999, "min_rounds": 50},
+	}
+}
+
+func (rtpAnomalyDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (rtpAnomalyDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	alpha := 1 - rule.param("confidence")
+	minRounds := int(rule.param("min_rounds"))
+	var events []SuspiciousEvent
+
+	for _, playerID := range sortedKeys(data.report.PlayerStats) {
+		test := data.report.PlayerStats[playerID].RTPTest
+		if test == nil || test.Rounds < minRounds || test.PValue >= alpha {
+			continue
+		}
+
+		events = append(events, SuspiciousEvent{
+			Type:        EventRTPAnomaly,
+			Description: "Player's RTP is improbably high for the games played",
+			PlayerID:    playerID,
+			Details: fmt.Sprintf("RTP %.2f%% vs expected %.2f%% over %d rounds, z = %.2f, p = %.2g",
+				test.ObservedRTP, test.ExpectedRTP, test.Rounds, test.ZScore, test.PValue),
+		})
+	}
+
+	return events
+}
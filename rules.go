@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severity ranks how serious a suspicious event is
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Scope selects the entity a rule evaluates
+type Scope string
+
+const (
+	ScopePlayer   Scope = "player"
+	ScopeGame     Scope = "game"
+	ScopeOperator Scope = "operator"
+)
+
+// severityRank orders severities from low (1) to critical (4)
+func severityRank(severity Severity) int {
+	switch severity {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityHigh:
+		return 3
+	case SeverityCritical:
+		return 4
+	}
+	return 0
+}
+
+func defaultSeverityScores() map[Severity]float64 {
+	return map[Severity]float64{
+		SeverityLow:      10,
+		SeverityMedium:   25,
+		SeverityHigh:     50,
+		SeverityCritical: 100,
+	}
+}
+
+// Rule configures one detector. Several rules may use the same detector,
+// for example to check RTP both per player and per operator.
+type Rule struct {
+	Name     string             `json:"name"`
+	Detector string             `json:"detector,omitempty"`
+	Enabled  bool               `json:"enabled"`
+	Severity Severity           `json:"severity"`
+	Scope    Scope              `json:"scope"`
+	Params   map[string]float64 `json:"params,omitempty"`
+
+	// Weight multiplies the severity score of the rule's events
+	Weight float64 `json:"weight"`
+}
+
+// ruleSet is the configured rules and the scores of their events
+type ruleSet struct {
+	Rules []Rule
+
+	// SeverityScores is the score of an event of each severity before the
+	// weight of its rule is applied
+	SeverityScores map[Severity]float64
+
+	// Games holds the expected return of games by game ID
+	Games map[string]GameProfile
+}
+
+// param returns a detector parameter; every parameter is present because
+// rules start from the detector defaults
+func (r Rule) param(name string) float64 {
+	return r.Params[name]
+}
+
+// Detector finds suspicious activity in the statistics of a report
+type Detector interface {
+	// Rule returns the default rule of the detector. Its name is the
+	// detector name and its params list every parameter the detector reads.
+	Rule() Rule
+	// Scopes lists the scopes the detector can evaluate
+	Scopes() []Scope
+	Detect(data *detectionData, rule Rule) []SuspiciousEvent
+}
+
+// detectionData is what detectors see of a report. Rounds and wallets are
+// nil for daily reports.
+type detectionData struct {
+	report    *Report
+	rounds    []*Round
+	wallet    []SuspiciousEvent
+	operators map[string]*activityTotals
+	windowEnd time.Time
+}
+
+// activityTotals aggregates the bets and wins of a group of players
+type activityTotals struct {
+	bets      int
+	wins      int
+	betAmount int64
+	winAmount int64
+	players   map[string]bool
+}
+
+func (t *activityTotals) rtp() float64 {
+	if t.betAmount == 0 {
+		return 0
+	}
+	return float64(t.winAmount) / float64(t.betAmount) * 100
+}
+
+// detectorRegistry holds the built-in detectors in the order their rules
+// are evaluated
+var detectorRegistry = []Detector{
+	highRTPDetector{},
+	rtpAnomalyDetector{},
+	hitRateDetector{},
+	spinRateDetector{},
+	botTimingDetector{},
+	martingaleDetector,
+	stepUpDetector,
+	repeatingStakeDetector,
+	orphanWinDetector,
+	multipleBetsDetector,
+	winBeforeBetDetector,
+	roundChangeDetector,
+	openRoundDetector,
+	walletBreakDetector{},
+	negativeBalanceDetector{},
+	collusionDetector{},
+	timestampMismatchDetector{},
+}
+
+func findDetector(name string) (Detector, bool) {
+	for _, detector := range detectorRegistry {
+		if detector.Rule().Name == name {
+			return detector, true
+		}
+	}
+	return nil, false
+}
+
+// defaultRules returns the rule of every built-in detector, with the
+// thresholds set on the command line
+func defaultRules(th thresholds) *ruleSet {
+	rules := make([]Rule, 0, len(detectorRegistry))
+	for _, detector := range detectorRegistry {
+		rule := detector.Rule()
+		rule.Detector = rule.Name
+		rule.Weight = 1
+		switch rule.Name {
+		case ruleHighRTP:
+			rule.Params["max_rtp"] = th.HighRTP
+			rule.Params["min_bets"] = float64(th.HighRTPMinBets)
+		case ruleHighSpinRate:
+			rule.Params["max_spins_per_minute"] = float64(th.MaxSpinsPerMinute)
+		}
+		rules = append(rules, rule)
+	}
+	return &ruleSet{Rules: rules, SeverityScores: defaultSeverityScores()}
+}
+
+// ruleOverride is a rule as written in a rules file, where omitted fields
+// keep their defaults
+type ruleOverride struct {
+	Name     string             `json:"name"`
+	Detector string             `json:"detector"`
+	Enabled  *bool              `json:"enabled"`
+	Severity Severity           `json:"severity"`
+	Scope    Scope              `json:"scope"`
+	Params   map[string]float64 `json:"params"`
+	Weight   *float64           `json:"weight"`
+}
+
+// loadRules returns the default rules merged with the rules file at path.
+// An entry named after an existing rule changes that rule; any other entry
+// adds a rule for the detector it names.
+func loadRules(path string, th thresholds) (*ruleSet, error) {
+	set := defaultRules(th)
+	if path == "" {
+		return set, nil
+	}
+
+	// The standard library has no YAML parser
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		return nil, fmt.Errorf("rules %s: YAML is not supported, convert the file to JSON", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules: %w", err)
+	}
+
+	var file struct {
+		Rules          []ruleOverride         `json:"rules"`
+		SeverityScores map[Severity]float64   `json:"severity_scores"`
+		Games          map[string]GameProfile `json:"games"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing rules %s: %w", path, err)
+	}
+
+	for severity, score := range file.SeverityScores {
+		if severityRank(severity) == 0 {
+			return nil, fmt.Errorf("rules %s: unknown severity %q in severity_scores", path, severity)
+		}
+		set.SeverityScores[severity] = score
+	}
+
+	for gameID, profile := range file.Games {
+		if profile.RTP < 0 || profile.StdDev < 0 {
+			return nil, fmt.Errorf("rules %s: game %s: rtp and std_dev must not be negative", path, gameID)
+		}
+		if profile.HitRate < 0 || profile.HitRate > 100 {
+			return nil, fmt.Errorf("rules %s: game %s: hit_rate must be between 0 and 100", path, gameID)
+		}
+	}
+	set.Games = file.Games
+
+	rules := set.Rules
+
+	for _, override := range file.Rules {
+		if override.Name == "" {
+			return nil, fmt.Errorf("rules %s: rule without a name", path)
+		}
+
+		index := -1
+		for i, rule := range rules {
+			if rule.Name == override.Name {
+				index = i
+				break
+			}
+		}
+
+		if index < 0 {
+			detector, ok := findDetector(override.Detector)
+			if !ok {
+				return nil, fmt.Errorf("rules %s: rule %q has unknown detector %q", path, override.Name, override.Detector)
+			}
+			rule := detector.Rule()
+			rule.Name = override.Name
+			rule.Detector = override.Detector
+			rule.Weight = 1
+			rules = append(rules, rule)
+			index = len(rules) - 1
+		} else if override.Detector != "" && override.Detector != rules[index].Detector {
+			return nil, fmt.Errorf("rules %s: rule %q uses detector %s", path, override.Name, rules[index].Detector)
+		}
+
+		if err := applyOverride(&rules[index], override); err != nil {
+			return nil, fmt.Errorf("rules %s: rule %q: %w", path, override.Name, err)
+		}
+	}
+
+	set.Rules = rules
+	return set, nil
+}
+
+func applyOverride(rule *Rule, override ruleOverride) error {
+	detector, _ := findDetector(rule.Detector)
+
+	if override.Enabled != nil {
+		rule.Enabled = *override.Enabled
+	}
+
+	if override.Severity != "" {
+		if severityRank(override.Severity) == 0 {
+			return fmt.Errorf("unknown severity %q", override.Severity)
+		}
+		rule.Severity = override.Severity
+	}
+
+	if override.Weight != nil {
+		if *override.Weight < 0 {
+			return fmt.Errorf("weight must not be negative")
+		}
+		rule.Weight = *override.Weight
+	}
+
+	if override.Scope != "" {
+		supported := false
+		for _, scope := range detector.Scopes() {
+			supported = supported || scope == override.Scope
+		}
+		if !supported {
+			return fmt.Errorf("detector %s does not support scope %q", rule.Detector, override.Scope)
+		}
+		rule.Scope = override.Scope
+	}
+
+	// Copy the params, rules created from the same defaults share the map
+	params := make(map[string]float64, len(rule.Params))
+	for name, value := range rule.Params {
+		params[name] = value
+	}
+	for name, value := range override.Params {
+		if _, ok := params[name]; !ok {
+			return fmt.Errorf("unknown param %q for detector %s", name, rule.Detector)
+		}
+		params[name] = value
+	}
+	rule.Params = params
+
+	return nil
+}
+
+// detect runs every enabled rule, labels the events with the rule name,
+// severity and score and returns them with the highest score first
+func detect(set *ruleSet, data *detectionData) []SuspiciousEvent {
+	events := []SuspiciousEvent{}
+
+	for _, rule := range set.Rules {
+		if !rule.Enabled {
+			continue
+		}
+		detector, ok := findDetector(rule.Detector)
+		if !ok {
+			continue
+		}
+
+		for _, event := range detector.Detect(data, rule) {
+			event.Rule = rule.Name
+			event.Severity = rule.Severity
+			event.Score = set.SeverityScores[rule.Severity] * rule.Weight
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Score > events[j].Score
+	})
+	return events
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
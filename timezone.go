@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Layouts of reported times. Timestamps of events keep milliseconds.
+const (
+	timeLayout      = time.RFC3339
+	timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+	dateLayout      = "2006-01-02"
+)
+
+// timeZones selects the zone times are reported and bucketed in: Default
+// for all data, or the zone of the operator for operators listed in
+// Operators
+type timeZones struct {
+	Default   *time.Location
+	Operators map[string]*time.Location
+}
+
+func (z *timeZones) String() string {
+	if z == nil || z.Default == nil {
+		return ""
+	}
+
+	parts := []string{z.Default.String()}
+	operators := make([]string, 0, len(z.Operators))
+	for operator := range z.Operators {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	for _, operator := range operators {
+		parts = append(parts, operator+"="+z.Operators[operator].String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a comma-separated list of ZONE or OPERATOR=ZONE entries, where
+// a zone is an IANA name such as "Africa/Lagos", "UTC", "Local" or a fixed
+// offset such as "+03:00"
+func (z *timeZones) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		operator, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			name = operator
+		}
+		location, err := loadZone(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			z.Operators[strings.TrimSpace(operator)] = location
+		} else {
+			z.Default = location
+		}
+	}
+	return nil
+}
+
+func loadZone(name string) (*time.Location, error) {
+	if name != "" && (name[0] == '+' || name[0] == '-') {
+		offset, err := time.Parse("-07:00", name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zone offset %q, expected +HH:MM", name)
+		}
+		_, seconds := offset.Zone()
+		return time.FixedZone(name, seconds), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return location, nil
+}
+
+// location returns the zone of an operator
+func (z *timeZones) location(operatorID string) *time.Location {
+	if location, ok := z.Operators[operatorID]; ok {
+		return location
+	}
+	return z.Default
+}
+
+// at converts a time into the zone of an operator
+func (z *timeZones) at(t time.Time, operatorID string) time.Time {
+	return t.In(z.location(operatorID))
+}
+
+// timestamp formats the time of an event in the zone of its operator
+func (z *timeZones) timestamp(t time.Time, operatorID string) string {
+	return z.at(t, operatorID).Format(timestampLayout)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// EventBotTiming flags a player whose bets follow a machine-like rhythm
+const EventBotTiming EventType = "Bot-like Bet Timing"
+
+// maxBetPauseSec separates playing sessions; longer intervals are pauses
+// and are left out of the timing statistics
+const maxBetPauseSec = 300
+
+// intervalBucketEdges are the upper bounds of the interval histogram in
+// seconds; the last bucket holds everything up to a pause
+var intervalBucketEdges = []float64{0.5, 1, 2, 5, 10, 30, 60, maxBetPauseSec}
+
+// BetTiming describes the distribution of the intervals between a player's
+// consecutive bets
+type BetTiming struct {
+	Intervals int     `json:"intervals"`
+	Pauses    int     `json:"pauses"`
+	MeanSec   float64 `json:"mean_sec"`
+	StdDevSec float64 `json:"stddev_sec"`
+
+	// CV is the coefficient of variation (stddev / mean)
+	CV float64 `json:"cv"`
+
+	// EntropyBits is the Shannon entropy of the intervals grouped into
+	// buckets 10% wide; regular timing concentrates in few buckets
+	EntropyBits float64 `json:"entropy_bits"`
+
+	// Periodicity is how closely bets fall on a grid of PeriodSec, the
+	// median interval, from 0 (no grid) to 1 (every bet on the grid)
+	Periodicity float64 `json:"periodicity"`
+	PeriodSec   float64 `json:"period_sec"`
+
+	Histogram []IntervalBucket `json:"histogram"`
+}
+
+// IntervalBucket counts the intervals up to MaxSec
+type IntervalBucket struct {
+	Label  string  `json:"label"`
+	MaxSec float64 `json:"max_sec"`
+	Count  int     `json:"count"`
+}
+
+// analyzeBetTiming computes the timing statistics of sorted bet timestamps.
+// It returns nil when there are fewer than two intervals within sessions.
+func analyzeBetTiming(timestamps []float64) *BetTiming {
+	timing := &BetTiming{}
+	var intervals []float64
+
+	for i := 1; i < len(timestamps); i++ {
+		interval := timestamps[i] - timestamps[i-1]
+		if interval > maxBetPauseSec {
+			timing.Pauses++
+			continue
+		}
+		intervals = append(intervals, interval)
+	}
+	if len(intervals) < 2 {
+		return nil
+	}
+	timing.Intervals = len(intervals)
+
+	var sum float64
+	for _, interval := range intervals {
+		sum += interval
+	}
+	timing.MeanSec = sum / float64(len(intervals))
+
+	var squares float64
+	for _, interval := range intervals {
+		squares += (interval - timing.MeanSec) * (interval - timing.MeanSec)
+	}
+	timing.StdDevSec = math.Sqrt(squares / float64(len(intervals)-1))
+	if timing.MeanSec > 0 {
+		timing.CV = timing.StdDevSec / timing.MeanSec
+	}
+
+	timing.EntropyBits = intervalEntropy(intervals)
+	timing.PeriodSec = median(intervals)
+	timing.Periodicity = gridAlignment(timestamps, timing.PeriodSec)
+	timing.Histogram = intervalHistogram(intervals)
+
+	return timing
+}
+
+// intervalEntropy is the entropy in bits of the intervals grouped into
+// logarithmic buckets 10% wide
+func intervalEntropy(intervals []float64) float64 {
+	counts := make(map[int]int)
+	for _, interval := range intervals {
+		// Intervals below 10ms share the lowest bucket
+		counts[int(math.Floor(math.Log(math.Max(interval, 0.01))/math.Log(1.1)))]++
+	}
+
+	var entropy float64
+	total := float64(len(intervals))
+	for _, count := range counts {
+		p := float64(count) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// gridAlignment returns the mean resultant length of the bet times taken
+// as phases of period: 1 when every bet falls on a multiple of the period,
+// close to 0 for irregular times
+func gridAlignment(timestamps []float64, period float64) float64 {
+	if period <= 0 || len(timestamps) == 0 {
+		return 0
+	}
+
+	var x, y float64
+	for _, ts := range timestamps {
+		phase := 2 * math.Pi * math.Mod(ts-timestamps[0], period) / period
+		x += math.Cos(phase)
+		y += math.Sin(phase)
+	}
+	return math.Hypot(x, y) / float64(len(timestamps))
+}
+
+func intervalHistogram(intervals []float64) []IntervalBucket {
+	buckets := make([]IntervalBucket, len(intervalBucketEdges))
+	lower := "0"
+	for i, edge := range intervalBucketEdges {
+		upper := formatSeconds(edge)
+		buckets[i] = IntervalBucket{Label: lower + "-" + upper, MaxSec: edge}
+		lower = upper
+	}
+
+	for _, interval := range intervals {
+		i := sort.SearchFloat64s(intervalBucketEdges, interval)
+		buckets[min(i, len(buckets)-1)].Count++
+	}
+	return buckets
+}
+
+func formatSeconds(sec float64) string {
+	if sec >= 60 {
+		return fmt.Sprintf("%gm", sec/60)
+	}
+	return fmt.Sprintf("%gs", sec)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// printIntervalHistogram draws the non-empty interval buckets as bars
+// below a player entry
+func printIntervalHistogram(w io.Writer, histogram []IntervalBucket) {
+	maxCount := 0
+	for _, bucket := range histogram {
+		maxCount = max(maxCount, bucket.Count)
+	}
+	if maxCount == 0 {
+		return
+	}
+
+	for _, bucket := range histogram {
+		if bucket.Count == 0 {
+			continue
+		}
+		bar := strings.Repeat("█", max(1, bucket.Count*20/maxCount))
+		fmt.Fprintf(w, "│    %-8s %s %d\n", bucket.Label, bar, bucket.Count)
+	}
+}
+
+// botTimingDetector flags players whose bet intervals are machine-like:
+// nearly constant (cv below max_cv), concentrated in few buckets (entropy
+// below max_entropy_bits) or locked to a grid (periodicity of at least
+// min_periodicity), over at least min_intervals intervals
+type botTimingDetector struct{}
+
+func (botTimingDetector) Rule() Rule {
+	return Rule{
+		Name:     "bot_timing",
+		Enabled:  true,
+		Severity: SeverityHigh,
+		Scope:    ScopePlayer,
+		Params: map[string]float64{
+			"min_intervals":    50,
+			"max_cv":           0.1,
+			"max_entropy_bits": 1.5,
+			"min_periodicity":  0.9,
+		},
+	}
+}
+
+func (botTimingDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (botTimingDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	var events []SuspiciousEvent
+
+	for _, playerID := range sortedKeys(data.report.PlayerStats) {
+		timing := data.report.PlayerStats[playerID].BetTiming
+		if timing == nil || timing.Intervals < int(rule.param("min_intervals")) {
+			continue
+		}
+
+		var reasons []string
+		if timing.CV < rule.param("max_cv") {
+			reasons = append(reasons, fmt.Sprintf("near-constant intervals (CV %.3f)", timing.CV))
+		}
+		if timing.EntropyBits < rule.param("max_entropy_bits") {
+			reasons = append(reasons, fmt.Sprintf("low interval entropy (%.2f bits)", timing.EntropyBits))
+		}
+		if timing.Periodicity >= rule.param("min_periodicity") {
+			reasons = append(reasons, fmt.Sprintf("bets on a %.2fs grid (periodicity %.2f)", timing.PeriodSec, timing.Periodicity))
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		events = append(events, SuspiciousEvent{
+			Type:        EventBotTiming,
+			Description: "Player's bet timing is machine-like (possible bot)",
+			PlayerID:    playerID,
+			Details: fmt.Sprintf("%s; mean interval %.2fs over %d intervals",
+				strings.Join(reasons, ", "), timing.MeanSec, timing.Intervals),
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Wallet reconciliation findings
+const (
+	EventUnexplainedCredit EventType = "Unexplained Credit"
+	EventMissingDebit      EventType = "Missing Debit"
+	EventUnexplainedDebit  EventType = "Unexplained Debit"
+	EventNegativeBalance   EventType = "Negative Balance"
+)
+
+// WalletSummary is the result of reconciling player balances against their
+// bets and wins
+type WalletSummary struct {
+	PlayersChecked     int `json:"players_checked"`
+	EventsChecked      int `json:"events_checked"`
+	Breaks             int `json:"breaks"`
+	UnexplainedCredits int `json:"unexplained_credits"`
+	MissingDebits      int `json:"missing_debits"`
+	UnexplainedDebits  int `json:"unexplained_debits"`
+	NegativeBalances   int `json:"negative_balances"`
+
+	// CreditAmount is the total balance gained and DebitAmount the total
+	// balance lost without a bet or win explaining it
+	CreditAmount int64 `json:"unexplained_credit_amount"`
+	DebitAmount  int64 `json:"unexplained_debit_amount"`
+}
+
+// walletEvent is a balance change recorded by a bet or win
+type walletEvent struct {
+	seq        int
+	time       time.Time
+	logTime    time.Time
+	step       int
+	bet        bool
+	amount     int64
+	balance    int64
+	roundID    string
+	gameID     string
+	operatorID string
+	platformID string
+	sourceSeq  int
+}
+
+// walletResult holds the reconciliation outcome of a single player
+type walletResult struct {
+	breaks      int
+	discrepancy int64
+}
+
+// walletLedger keeps every balance change per player while data is streamed
+type walletLedger struct {
+	players map[string][]walletEvent
+	seq     int
+
+	// filtered checks the balance only against a previous event read right
+	// before or after it, for data with events filtered out
+	filtered bool
+}
+
+func newWalletLedger() *walletLedger {
+	return &walletLedger{players: make(map[string][]walletEvent)}
+}
+
+func (l *walletLedger) add(data GameData) {
+	event := walletEvent{
+		seq:        l.seq,
+		time:       data.Time,
+		logTime:    data.LogTime,
+		step:       data.StepNumber,
+		balance:    data.Balance,
+		roundID:    data.RoundID,
+		gameID:     data.GameID,
+		operatorID: data.OperatorID,
+		platformID: data.PlatformID,
+		sourceSeq:  data.sourceSeq,
+	}
+	switch data.Message {
+	case "SendBet":
+		event.bet = true
+		event.amount = data.Bet
+	case "SendWin":
+		event.amount = data.Win
+	default:
+		return
+	}
+
+	l.seq++
+	l.players[data.PlayerID] = append(l.players[data.PlayerID], event)
+}
+
+// reconcile walks each player's balance changes in chronological order and
+// checks that every bet debits and every win credits exactly its amount
+func (l *walletLedger) reconcile(currency currencyFormat, zones *timeZones) (*WalletSummary, []SuspiciousEvent, map[string]walletResult) {
+	summary := &WalletSummary{PlayersChecked: len(l.players)}
+	results := make(map[string]walletResult)
+	var events []SuspiciousEvent
+
+	playerIDs := make([]string, 0, len(l.players))
+	for playerID := range l.players {
+		playerIDs = append(playerIDs, playerID)
+	}
+	sort.Strings(playerIDs)
+
+	for _, playerID := range playerIDs {
+		ledger := l.players[playerID]
+		sort.Slice(ledger, func(i, j int) bool {
+			a, b := ledger[i], ledger[j]
+			if !a.time.Equal(b.time) {
+				return a.time.Before(b.time)
+			}
+			if a.step != b.step {
+				return a.step < b.step
+			}
+			if before, ok := loggedBefore(a.logTime, b.logTime); ok {
+				return before
+			}
+			if a.bet != b.bet {
+				return a.bet
+			}
+			return a.seq < b.seq
+		})
+
+		var result walletResult
+		for i, event := range ledger {
+			summary.EventsChecked++
+
+			newEvent := func(eventType EventType, description string, amount int64, details string) SuspiciousEvent {
+				return SuspiciousEvent{
+					Type:        eventType,
+					Description: description,
+					PlayerID:    playerID,
+					GameID:      event.gameID,
+					OperatorID:  event.operatorID,
+					PlatformID:  event.platformID,
+					RoundID:     event.roundID,
+					Timestamp:   zones.timestamp(event.time, event.operatorID),
+					Amount:      amount,
+					Details:     details,
+				}
+			}
+
+			if event.balance < 0 && (i == 0 || ledger[i-1].balance >= 0) {
+				summary.NegativeBalances++
+				events = append(events, newEvent(EventNegativeBalance,
+					"Player balance dropped below zero", event.balance,
+					fmt.Sprintf("Balance %s", formatMoney(event.balance, currency))))
+			}
+
+			if i == 0 || (l.filtered && !readAdjacent(ledger[i-1], event)) {
+				continue
+			}
+
+			previous := ledger[i-1].balance
+			expected := previous + event.amount
+			change := "win of"
+			if event.bet {
+				expected = previous - event.amount
+				change = "bet of"
+			}
+
+			discrepancy := event.balance - expected
+			if discrepancy == 0 {
+				continue
+			}
+
+			summary.Breaks++
+			result.breaks++
+			result.discrepancy += discrepancy
+
+			details := fmt.Sprintf("Balance %s → %s after %s %s, expected %s (discrepancy %s)",
+				formatMoney(previous, currency), formatMoney(event.balance, currency),
+				change, formatMoney(event.amount, currency),
+				formatMoney(expected, currency), formatMoney(discrepancy, currency))
+
+			switch {
+			case discrepancy > 0 && event.bet:
+				summary.MissingDebits++
+				summary.CreditAmount += discrepancy
+				events = append(events, newEvent(EventMissingDebit,
+					"Bet was not fully debited from the balance", discrepancy, details))
+			case discrepancy > 0:
+				summary.UnexplainedCredits++
+				summary.CreditAmount += discrepancy
+				events = append(events, newEvent(EventUnexplainedCredit,
+					"Balance grew by more than the win explains", discrepancy, details))
+			default:
+				summary.UnexplainedDebits++
+				summary.DebitAmount -= discrepancy
+				events = append(events, newEvent(EventUnexplainedDebit,
+					"Balance fell by more than the bet or win explains", discrepancy, details))
+			}
+		}
+
+		results[playerID] = result
+	}
+
+	return summary, events, results
+}
+
+// readAdjacent reports whether no bet or win of the player was read between
+// two events, in either direction
+func readAdjacent(a, b walletEvent) bool {
+	return a.sourceSeq-b.sourceSeq == 1 || b.sourceSeq-a.sourceSeq == 1
+}
+
+func printWalletSummary(w io.Writer, summary *WalletSummary, currency currencyFormat) {
+	fmt.Fprintln(w, "\n🧾 WALLET RECONCILIATION:")
+	fmt.Fprintf(w, "├─ Checked: %d balance changes, %d players\n", summary.EventsChecked, summary.PlayersChecked)
+	if summary.Breaks == 0 && summary.NegativeBalances == 0 {
+		fmt.Fprintf(w, "└─ ✅ Every balance change is explained by a bet or win\n")
+		return
+	}
+	fmt.Fprintf(w, "├─ ⚠️  Breaks: %d (unexplained credits: %d, missing debits: %d, unexplained debits: %d)\n",
+		summary.Breaks, summary.UnexplainedCredits, summary.MissingDebits, summary.UnexplainedDebits)
+	fmt.Fprintf(w, "├─ Unexplained Credit: %s\n", formatMoney(summary.CreditAmount, currency))
+	fmt.Fprintf(w, "├─ Unexplained Debit: %s\n", formatMoney(summary.DebitAmount, currency))
+	fmt.Fprintf(w, "└─ Negative Balances: %d\n", summary.NegativeBalances)
+}
+
+// walletBreakDetector reports balance changes not explained by a bet or win
+// whose discrepancy is at least min_discrepancy minor units
+type walletBreakDetector struct{}
+
+func (walletBreakDetector) Rule() Rule {
+	return Rule{
+		Name:     "wallet_break",
+		Enabled:  true,
+		Severity: SeverityCritical,
+		Scope:    ScopePlayer,
+		Params:   map[string]float64{"min_discrepancy": 1},
+	}
+}
+
+func (walletBreakDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (walletBreakDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	var events []SuspiciousEvent
+	for _, event := range data.wallet {
+		if event.Type == EventNegativeBalance {
+			continue
+		}
+		if math.Abs(float64(event.Amount)) >= rule.param("min_discrepancy") {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// negativeBalanceDetector reports players whose balance drops below zero
+type negativeBalanceDetector struct{}
+
+func (negativeBalanceDetector) Rule() Rule {
+	return Rule{Name: "negative_balance", Enabled: true, Severity: SeverityHigh, Scope: ScopePlayer}
+}
+
+func (negativeBalanceDetector) Scopes() []Scope {
+	return []Scope{ScopePlayer}
+}
+
+func (negativeBalanceDetector) Detect(data *detectionData, rule Rule) []SuspiciousEvent {
+	var events []SuspiciousEvent
+	for _, event := range data.wallet {
+		if event.Type == EventNegativeBalance {
+			events = append(events, event)
+		}
+	}
+	return events
+}